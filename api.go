@@ -0,0 +1,278 @@
+package simpleCache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* 面向客户端的REST接口, 和节点之间使用的HttpPool分开
+ * GET    /groups                        所有group的统计信息
+ * GET    /groups/{group}                指定group的统计信息
+ * GET    /groups/{group}/keys/{key}     读取数据, 支持If-None-Match
+ * PUT    /groups/{group}/keys/{key}     写入数据, 可以用?ttl=30s指定存活时间
+ * DELETE /groups/{group}/keys/{key}     删除数据
+ * 写入和删除只在负责key的节点上执行, 其它节点返回307, 重定向到负责的节点
+ * POST   /groups/{group}/snapshot       把group写入配置的快照文件
+ * GET    /peers                         集群成员, 需要先调用RegisterPeers
+ * group和key需要进行路径转义
+ */
+
+const (
+	apiGroupsPath = "/groups"
+	apiPeersPath  = "/peers"
+
+	// PUT请求体的大小上限
+	maxAPIBodyBytes = 64 << 20
+)
+
+// APIHandler 提供给非Go服务使用的HTTP接口
+type APIHandler struct {
+	registry *Registry
+	peers    PeerLister
+}
+
+// NewAPIHandler 操作DefaultRegistry中的group
+func NewAPIHandler() *APIHandler {
+	return DefaultRegistry.NewAPIHandler()
+}
+
+// RegisterPeers 设置/peers接口使用的成员信息来源, 通常就是本节点的HttpPool
+// peers实现了PeerOwner时, 写请求会被重定向到负责key的节点, 要求各节点的API和节点名字使用同一个地址
+func (h *APIHandler) RegisterPeers(peers PeerLister) {
+	h.peers = peers
+}
+
+// PeersInfo /peers接口的返回值
+type PeersInfo struct {
+	Self  string   `json:"self"`
+	Peers []string `json:"peers"`
+}
+
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.EscapedPath()
+	if path == apiPeersPath {
+		h.servePeers(w, req)
+		return
+	}
+	if path != apiGroupsPath && !strings.HasPrefix(path, apiGroupsPath+"/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	// 拆分成 group / "keys" / key, key中可以包含转义后的'/'
+	parts := strings.SplitN(strings.TrimPrefix(path[len(apiGroupsPath):], "/"), "/", 3)
+	if parts[0] == "" {
+		h.serveGroups(w, req)
+		return
+	}
+
+	groupName, err := url.PathUnescape(parts[0])
+	if err != nil {
+		http.Error(w, "bad group name", http.StatusBadRequest)
+		return
+	}
+	group := h.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		h.serveGroup(w, req, group)
+	case parts[1] == "snapshot" && len(parts) == 2:
+		h.serveSnapshot(w, req, group)
+	case parts[1] == "keys" && len(parts) == 3:
+		key, err := url.PathUnescape(parts[2])
+		if err != nil || key == "" {
+			http.Error(w, "bad key", http.StatusBadRequest)
+			return
+		}
+		h.serveKey(w, req, group, key)
+	case parts[1] == "keys":
+		http.Error(w, "empty key", http.StatusBadRequest)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (h *APIHandler) serveGroups(w http.ResponseWriter, req *http.Request) {
+	if !allowMethods(w, req, http.MethodGet) {
+		return
+	}
+	names := h.registry.GroupNames()
+	stats := make([]Stats, 0, len(names))
+	for _, name := range names {
+		if g := h.registry.GetGroup(name); g != nil {
+			stats = append(stats, g.Stats())
+		}
+	}
+	writeJSON(w, stats)
+}
+
+func (h *APIHandler) serveGroup(w http.ResponseWriter, req *http.Request, group *Group) {
+	if !allowMethods(w, req, http.MethodGet) {
+		return
+	}
+	writeJSON(w, group.Stats())
+}
+
+func (h *APIHandler) servePeers(w http.ResponseWriter, req *http.Request) {
+	if !allowMethods(w, req, http.MethodGet) {
+		return
+	}
+	if h.peers == nil {
+		http.Error(w, "peers are not registered", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, PeersInfo{Self: h.peers.Self(), Peers: h.peers.Peers()})
+}
+
+func (h *APIHandler) serveSnapshot(w http.ResponseWriter, req *http.Request, group *Group) {
+	if !allowMethods(w, req, http.MethodPost) {
+		return
+	}
+	if err := group.SaveSnapshot(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNoSnapshotFile) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandler) serveKey(w http.ResponseWriter, req *http.Request, group *Group, key string) {
+	if !allowMethods(w, req, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete) {
+		return
+	}
+
+	// 写入只对本节点的缓存生效, 不负责这个key时交给负责的节点处理
+	if req.Method == http.MethodPut || req.Method == http.MethodDelete {
+		if owner := h.owner(key); owner != "" {
+			http.Redirect(w, req, owner+req.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		data, err := group.Get(key)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		header := w.Header()
+		etag := strconv.Quote(data.Version())
+		header.Set("ETag", etag)
+		if !data.CreateTime().IsZero() {
+			header.Set("Last-Modified", data.CreateTime().UTC().Format(http.TimeFormat))
+		}
+		if !data.Expire().IsZero() {
+			header.Set("Expires", data.Expire().UTC().Format(http.TimeFormat))
+		}
+		if data.Stale() {
+			header.Set("Warning", `110 - "Response is Stale"`)
+		}
+		if matchETag(req.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		contentType := data.ContentType()
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+		value := data.bytes()
+		header.Set("Content-Length", strconv.Itoa(len(value)))
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(value)
+		}
+
+	case http.MethodPut:
+		var opts []SetOption
+		if ttl := req.URL.Query().Get("ttl"); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil || d < 0 {
+				http.Error(w, "bad ttl: "+ttl, http.StatusBadRequest)
+				return
+			}
+			opts = append(opts, SetTTL(d))
+		}
+		if contentType := req.Header.Get("Content-Type"); contentType != "" {
+			opts = append(opts, SetContentType(contentType))
+		}
+
+		value, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxAPIBodyBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err = group.Set(key, value, opts...); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("ETag", strconv.Quote(versionOf(value)))
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		group.Remove(key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// owner 负责key的其它节点, 由本节点负责或者无法确定时返回空字符串
+func (h *APIHandler) owner(key string) string {
+	po, ok := h.peers.(PeerOwner)
+	if !ok {
+		return ""
+	}
+	if owner := po.Owner(key); owner != h.peers.Self() {
+		return owner
+	}
+	return ""
+}
+
+// allowMethods 请求方法不在methods中时返回405
+func allowMethods(w http.ResponseWriter, req *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if req.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// matchETag 判断If-None-Match中是否包含etag
+func matchETag(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,202 @@
+package simpleCache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIHandler(t *testing.T) {
+	reg := NewRegistry()
+	newTestGroup(t, reg, "api-scores", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, ErrNotFound)
+		}))
+	server := httptest.NewServer(reg.NewAPIHandler())
+	defer server.Close()
+
+	do := func(method, path string, body string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	read := func(resp *http.Response) string {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	resp := do(http.MethodGet, "/groups/api-scores/keys/Tom", "", nil)
+	if resp.StatusCode != http.StatusOK || read(resp) != "630" {
+		t.Fatalf("get Tom got status %d", resp.StatusCode)
+	}
+	etag := resp.Header.Get("ETag")
+	resp = do(http.MethodGet, "/groups/api-scores/keys/Tom", "", map[string]string{"If-None-Match": etag})
+	read(resp)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional get got status %d", resp.StatusCode)
+	}
+
+	cases := map[string]int{
+		"/groups/unknown/keys/Tom":    http.StatusNotFound,
+		"/groups/api-scores/keys/kkk": http.StatusNotFound,
+		"/groups/api-scores/keys/":    http.StatusBadRequest,
+	}
+	for path, status := range cases {
+		if resp = do(http.MethodGet, path, "", nil); resp.StatusCode != status {
+			t.Fatalf("get %s got status %d, want %d", path, resp.StatusCode, status)
+		}
+		read(resp)
+	}
+
+	// key中包含转义后的'/'
+	resp = do(http.MethodPut, "/groups/api-scores/keys/a%2Fb?ttl=1h", `{"v":1}`,
+		map[string]string{"Content-Type": "application/json"})
+	read(resp)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("put got status %d", resp.StatusCode)
+	}
+	resp = do(http.MethodGet, "/groups/api-scores/keys/a%2Fb", "", nil)
+	if body := read(resp); body != `{"v":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("content type is not passed through: %s %s", body, resp.Header.Get("Content-Type"))
+	}
+
+	read(do(http.MethodDelete, "/groups/api-scores/keys/a%2Fb", "", nil))
+	if resp = do(http.MethodGet, "/groups/api-scores/keys/a%2Fb", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted key got status %d", resp.StatusCode)
+	}
+	read(resp)
+
+	var stats []Stats
+	_ = json.Unmarshal([]byte(read(do(http.MethodGet, "/groups", "", nil))), &stats)
+	found := false
+	for _, s := range stats {
+		if s.Name == "api-scores" {
+			found = s.Gets > 0 && s.Hits > 0
+		}
+	}
+	if !found {
+		t.Fatalf("stats of api-scores are not listed: %+v", stats)
+	}
+}
+
+func TestAPIPeersAndSnapshot(t *testing.T) {
+	reg := NewRegistry()
+	path := filepath.Join(t.TempDir(), "api.snap")
+	g := newTestGroup(t, reg, "api-snapshot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithSnapshotFile(path, 0))
+	_, _ = g.Get("k")
+
+	pool := reg.NewHttpPool("http://node1")
+	pool.Set("http://node1", "http://node2")
+	h := reg.NewAPIHandler()
+	h.RegisterPeers(pool)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info PeersInfo
+	_ = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if info.Self != "http://node1" || len(info.Peers) != 2 {
+		t.Fatalf("peers got %+v", info)
+	}
+
+	resp, err = http.Post(server.URL+"/groups/api-snapshot/snapshot", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("snapshot got status %d", resp.StatusCode)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Fatal("snapshot file is not written")
+	}
+
+	newTestGroup(t, reg, "api-no-snapshot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	resp, _ = http.Post(server.URL+"/groups/api-no-snapshot/snapshot", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("snapshot without file got status %d", resp.StatusCode)
+	}
+}
+
+func TestAPIRedirectWrites(t *testing.T) {
+	reg := NewRegistry()
+	g := newTestGroup(t, reg, "api-owner", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	pool := reg.NewHttpPool("http://node1")
+	pool.Set("http://node1", "http://node2")
+	h := reg.NewAPIHandler()
+	h.RegisterPeers(pool)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	// 找出分别由两个节点负责的key
+	var local, remote string
+	for i := 0; local == "" || remote == ""; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if pool.Owner(key) == "http://node1" {
+			local = key
+		} else {
+			remote = key
+		}
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	do := func(method, key string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+"/groups/api-owner/keys/"+key+"?ttl=1h", strings.NewReader("v"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		resp := do(method, remote)
+		want := "http://node2/groups/api-owner/keys/" + remote + "?ttl=1h"
+		if resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get("Location") != want {
+			t.Fatalf("%s %s got status %d, location %q", method, remote, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+	if _, ok := g.mainCache.get(remote); ok {
+		t.Fatal("redirected write should not modify the local cache")
+	}
+
+	if resp := do(http.MethodPut, local); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("put %s got status %d", local, resp.StatusCode)
+	}
+	if v, ok := g.mainCache.get(local); !ok || v.String() != "v" {
+		t.Fatal("write of a local key should be applied")
+	}
+}
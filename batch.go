@@ -0,0 +1,115 @@
+package simpleCache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBatchWindow = time.Millisecond
+	defaultMaxBatch    = 100
+)
+
+// BatchGetter 可以一次从数据源读取多个key的Getter, 比如用一条 WHERE id IN (...) 查询
+// 返回的values和errs与keys一一对应, 没有出错时errs可以为nil
+// 通过WithBatching开启批量加载后, 同一时间窗口内未命中的key会合并成一次GetBatch
+type BatchGetter interface {
+	Getter
+	GetBatch(keys []string) (values [][]byte, errs []error)
+}
+
+// batcher 收集并发的本地加载请求, 窗口结束或达到数量上限时一起交给GetBatch
+type batcher struct {
+	window   time.Duration
+	maxBatch int
+	stats    *groupStats
+
+	mu      sync.Mutex
+	pending *batch // 正在收集key的批次
+}
+
+type batch struct {
+	getter BatchGetter // 批次中第一个请求看到的数据源
+	keys   []string
+	timer  *time.Timer
+
+	done   chan struct{} // 加载结束后关闭
+	values [][]byte
+	errs   []error
+}
+
+// get 把key加入当前批次, 等待整个批次加载完成后返回这个key的结果
+func (b *batcher) get(getter BatchGetter, key string) ([]byte, error) {
+	b.mu.Lock()
+	bt := b.pending
+	if bt == nil {
+		bt = &batch{getter: getter, done: make(chan struct{})}
+		bt.timer = time.AfterFunc(b.window, func() { b.flush(bt) })
+		b.pending = bt
+	}
+	i := len(bt.keys)
+	bt.keys = append(bt.keys, key)
+	full := len(bt.keys) >= b.maxBatch
+	if full {
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	// 达到上限时由最后加入的请求直接执行
+	if full {
+		bt.timer.Stop()
+		b.run(bt)
+	}
+	<-bt.done
+	return bt.values[i], bt.errs[i]
+}
+
+// flush 时间窗口结束, 批次没有因为达到上限被执行时在这里执行
+func (b *batcher) flush(bt *batch) {
+	b.mu.Lock()
+	if b.pending != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.pending = nil
+	b.mu.Unlock()
+	b.run(bt)
+}
+
+func (b *batcher) run(bt *batch) {
+	defer close(bt.done)
+	atomic.AddInt64(&b.stats.batchLoads, 1)
+
+	values, errs, err := callBatch(bt.getter, bt.keys)
+	if err == nil && len(values) != len(bt.keys) {
+		err = fmt.Errorf("batch getter returned %d values for %d keys", len(values), len(bt.keys))
+	}
+	if err == nil && errs != nil && len(errs) != len(bt.keys) {
+		err = fmt.Errorf("batch getter returned %d errors for %d keys", len(errs), len(bt.keys))
+	}
+	if err != nil {
+		values = make([][]byte, len(bt.keys))
+		errs = make([]error, len(bt.keys))
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	if errs == nil {
+		errs = make([]error, len(bt.keys))
+	}
+	bt.values, bt.errs = values, errs
+}
+
+// callBatch 调用GetBatch, 把panic转换成整个批次的错误
+// 批次可能在定时器的goroutine中执行, panic没有人能够recover
+func callBatch(getter BatchGetter, keys []string) (values [][]byte, errs []error, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("batch getter panicked: %v", r)
+		}
+	}()
+	values, errs = getter.GetBatch(keys)
+	return values, errs, nil
+}
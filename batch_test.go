@@ -0,0 +1,134 @@
+package simpleCache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchDB 记录每次GetBatch收到的key, key为"missing"时返回ErrNotFound
+type batchDB struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (d *batchDB) Get(key string) ([]byte, error) {
+	values, errs := d.GetBatch([]string{key})
+	return values[0], errs[0]
+}
+
+func (d *batchDB) GetBatch(keys []string) ([][]byte, []error) {
+	d.mu.Lock()
+	d.batches = append(d.batches, keys)
+	d.mu.Unlock()
+
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if key == "missing" {
+			errs[i] = fmt.Errorf("%s: %w", key, ErrNotFound)
+			continue
+		}
+		if key == "panic" {
+			panic("broken database")
+		}
+		values[i] = []byte("v" + key)
+	}
+	return values, errs
+}
+
+func (d *batchDB) sizes() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sizes := make([]int, len(d.batches))
+	for i, b := range d.batches {
+		sizes[i] = len(b)
+	}
+	return sizes
+}
+
+// getAll 并发读取keys, 返回每个key的结果
+func getAll(g *Group, keys []string) ([]ByteView, []error) {
+	views := make([]ByteView, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			views[i], errs[i] = g.Get(key)
+		}(i, key)
+	}
+	wg.Wait()
+	return views, errs
+}
+
+func TestBatching(t *testing.T) {
+	reg := NewRegistry()
+	db := &batchDB{}
+	g := newTestGroup(t, reg, "batch", 0, db, WithBatching(50*time.Millisecond, 100))
+	defer g.Close()
+
+	keys := []string{"missing"}
+	for i := 0; i < 9; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	views, errs := getAll(g, keys)
+	if !errors.Is(errs[0], ErrNotFound) {
+		t.Fatalf("missing key got %v", errs[0])
+	}
+	for i := 1; i < len(keys); i++ {
+		if errs[i] != nil || views[i].String() != "v"+keys[i] {
+			t.Fatalf("get %s got %v, %v", keys[i], views[i], errs[i])
+		}
+	}
+	if sizes := db.sizes(); len(sizes) != 1 || sizes[0] != len(keys) {
+		t.Fatalf("batch sizes %v, want one batch of %d keys", sizes, len(keys))
+	}
+	if stats := g.Stats(); stats.BatchLoads != 1 || stats.LocalLoads != 10 || stats.LocalErrors != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	// 缓存命中的key不会进入批次
+	if _, err := g.Get("1"); err != nil || len(db.sizes()) != 1 {
+		t.Fatal("cached key should not be loaded again")
+	}
+}
+
+func TestBatchingMaxSize(t *testing.T) {
+	reg := NewRegistry()
+	db := &batchDB{}
+	// 窗口足够长, 只有凑够数量才会加载
+	g := newTestGroup(t, reg, "batch-max", 0, db, WithBatching(time.Hour, 3))
+	defer g.Close()
+
+	_, errs := getAll(g, []string{"a", "b", "c", "d", "e", "f"})
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sizes := db.sizes(); len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 3 {
+		t.Fatalf("batch sizes %v", sizes)
+	}
+}
+
+func TestBatchingPanic(t *testing.T) {
+	reg := NewRegistry()
+	g := newTestGroup(t, reg, "batch-panic", 0, &batchDB{}, WithBatching(10*time.Millisecond, 0))
+	defer g.Close()
+
+	// 一个key导致panic时整个批次都返回错误
+	_, errs := getAll(g, []string{"panic", "a"})
+	for _, err := range errs {
+		if err == nil {
+			t.Fatal("keys in a panicked batch should fail")
+		}
+	}
+	if view, err := g.Get("b"); err != nil || view.String() != "vb" {
+		t.Fatalf("next batch got %v, %v", view, err)
+	}
+}
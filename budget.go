@@ -0,0 +1,110 @@
+package simpleCache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Budget 多个group共享的内存预算, 通过WithBudget加入
+// 所有成员内存缓存的总大小超过limit时, 在成员之间挑选数据淘汰:
+// 每个成员最旧的数据按 闲置时间/权重 比较, 值最大的先被淘汰
+// 权重都相同时近似于跨group的LRU, 权重越大的group数据保留得越久
+// 内存用量不超过保留额度的成员不会被挑中, 空闲的group会把多余的内存让给繁忙的group
+// 每个group自己的cacheBytes仍然有效, 0表示只受预算的限制
+type Budget struct {
+	limit int64 // 原子操作
+	used  int64 // 原子操作
+
+	mu      sync.Mutex // 保护members, 同时保证只有一个goroutine在回收内存
+	members map[*cache]struct{}
+	now     func() int64
+}
+
+// NewBudget limit为预算的总字节数
+func NewBudget(limit int64) *Budget {
+	return &Budget{
+		limit:   limit,
+		members: make(map[*cache]struct{}),
+		now:     func() int64 { return time.Now().UnixNano() },
+	}
+}
+
+// Limit 预算的总字节数
+func (b *Budget) Limit() int64 {
+	return atomic.LoadInt64(&b.limit)
+}
+
+// Used 所有成员内存缓存当前占用的字节数
+func (b *Budget) Used() int64 {
+	return atomic.LoadInt64(&b.used)
+}
+
+// SetLimit 修改预算, 缩小时立即淘汰数据
+func (b *Budget) SetLimit(limit int64) {
+	atomic.StoreInt64(&b.limit, limit)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reclaimLocked()
+}
+
+func (b *Budget) join(c *cache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.members[c] = struct{}{}
+}
+
+func (b *Budget) leave(c *cache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.members, c)
+}
+
+func (b *Budget) add(delta int64) {
+	atomic.AddInt64(&b.used, delta)
+}
+
+func (b *Budget) over() bool {
+	return atomic.LoadInt64(&b.used) > atomic.LoadInt64(&b.limit)
+}
+
+// reclaim 超出预算时淘汰数据, 调用方不能持有任何成员的锁
+// 已经有其它goroutine在回收时直接返回
+func (b *Budget) reclaim() {
+	if !b.over() || !b.mu.TryLock() {
+		return
+	}
+	defer b.mu.Unlock()
+	b.reclaimLocked()
+}
+
+func (b *Budget) reclaimLocked() {
+	for b.over() {
+		victim := b.pickVictim()
+		if victim == nil {
+			// 所有成员都在保留额度以内
+			return
+		}
+		victim.evictOldest()
+	}
+}
+
+// pickVictim 挑选最旧数据 闲置时间/权重 最大的成员, 每次只锁住一个成员
+func (b *Budget) pickVictim() *cache {
+	now := b.now()
+	var (
+		victim *cache
+		best   float64
+	)
+	for c := range b.members {
+		atime, bytes, ok := c.oldest()
+		if !ok || bytes <= c.reserve {
+			continue
+		}
+		score := float64(now-atime) / float64(c.weight)
+		if victim == nil || score > best {
+			victim, best = c, score
+		}
+	}
+	return victim
+}
@@ -0,0 +1,167 @@
+package simpleCache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBudgetGroups 创建共享同一个预算的group, 使用递增的逻辑时钟保证访问顺序确定
+func newBudgetGroups(t *testing.T, limit int64, opts ...[2]int64) (*Budget, []*Group) {
+	b := NewBudget(limit)
+	var tick int64
+	b.now = func() int64 { return atomic.AddInt64(&tick, 1) }
+
+	reg := NewRegistry()
+	var res []*Group
+	for i, o := range opts {
+		res = append(res, newTestGroup(t, reg, fmt.Sprintf("budget-%d", i), 0, GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte("12345678"), nil
+			}), WithBudget(b, o[0], int(o[1]))))
+	}
+	return b, res
+}
+
+// fill 写入n条10字节的数据
+func fill(g *Group, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		_ = g.Set(fmt.Sprintf("%s%d", prefix, i), []byte("12345678"))
+	}
+}
+
+func cached(g *Group, key string) bool {
+	_, ok := g.mainCache.get(key)
+	return ok
+}
+
+func TestBudgetRecency(t *testing.T) {
+	b, gs := newBudgetGroups(t, 50, [2]int64{0, 1}, [2]int64{0, 1})
+	a, c := gs[0], gs[1]
+	fill(a, "a", 5)
+	if b.Used() != 50 {
+		t.Fatalf("used %d, want 50", b.Used())
+	}
+
+	// 超出预算时淘汰所有group中最旧的数据
+	fill(c, "b", 1)
+	if cached(a, "a0") || !cached(a, "a1") || b.Used() != 50 {
+		t.Fatalf("a0 should be evicted first, used %d", b.Used())
+	}
+	// 访问过的数据变成最新的
+	cached(a, "a1")
+	fill(c, "c", 1)
+	if !cached(a, "a1") || cached(a, "a2") {
+		t.Fatal("a2 should be evicted before the recently used a1")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Used() != 30 {
+		t.Fatalf("closed group should release its memory, used %d", b.Used())
+	}
+}
+
+func TestBudgetReserve(t *testing.T) {
+	b, gs := newBudgetGroups(t, 50, [2]int64{30, 1}, [2]int64{0, 1})
+	idle, busy := gs[0], gs[1]
+	fill(idle, "a", 5)
+	fill(busy, "b", 10)
+
+	if st := idle.Stats(); st.Bytes != 30 {
+		t.Fatalf("idle group should keep its reservation, has %d bytes", st.Bytes)
+	}
+	if st := busy.Stats(); st.Bytes != 20 || b.Used() != 50 {
+		t.Fatalf("busy group has %d bytes, used %d", st.Bytes, b.Used())
+	}
+}
+
+func TestBudgetWeight(t *testing.T) {
+	_, gs := newBudgetGroups(t, 50, [2]int64{0, 100}, [2]int64{0, 1})
+	important, normal := gs[0], gs[1]
+	fill(important, "a", 2)
+	fill(normal, "b", 10)
+
+	// 权重大的group的数据虽然更旧, 但保留得更久
+	if st := important.Stats(); st.Items != 2 {
+		t.Fatalf("important group has %d items, want 2", st.Items)
+	}
+	if st := normal.Stats(); st.Items != 3 {
+		t.Fatalf("normal group has %d items, want 3", st.Items)
+	}
+}
+
+func TestBudgetSetLimit(t *testing.T) {
+	b, gs := newBudgetGroups(t, 100, [2]int64{0, 1})
+	fill(gs[0], "a", 10)
+	b.SetLimit(40)
+	if b.Used() != 40 || gs[0].Stats().Items != 4 {
+		t.Fatalf("SetLimit should evict immediately, used %d", b.Used())
+	}
+}
+
+func TestBudgetCloseWhileLoading(t *testing.T) {
+	b := NewBudget(100)
+	reg := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("12345678"), nil
+	})
+	busy := newTestGroup(t, reg, "budget-busy", 0, getter, WithBudget(b, 0, 1))
+	defer busy.Close()
+
+	// 一边不断加载触发跨group回收, 一边关闭其它group, 两者不能互相等待
+	stop := make(chan struct{})
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				_, _ = busy.Get(fmt.Sprintf("k%d", i))
+			}
+		}
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		deadline := time.Now().Add(500 * time.Millisecond)
+		for i := 0; time.Now().Before(deadline); i++ {
+			g := newTestGroup(t, reg, fmt.Sprintf("budget-closing-%d", i), 0, getter, WithBudget(b, 0, 1))
+			fill(g, "a", 5)
+			_ = g.Close()
+		}
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("closing a group deadlocked with budget reclaim")
+	}
+	close(stop)
+	<-loaded
+}
+
+func TestBudgetWithStaleIfError(t *testing.T) {
+	b := NewBudget(1000)
+	g := newTestGroup(t, NewRegistry(), "budget-stale", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return make([]byte, 100), nil
+		}), WithBudget(b, 0, 1), WithStaleIfError(time.Hour))
+	for i := 0; i < 1000; i++ {
+		_, _ = g.Get(fmt.Sprintf("k%d", i))
+	}
+
+	// 预算不足时淘汰的数据不能进入不计入预算的宽限区
+	c := &g.mainCache
+	c.mu.Lock()
+	retained := c.mem.Bytes() + c.grace.Bytes()
+	c.mu.Unlock()
+	if b.Used() > b.Limit() || retained > b.Limit() {
+		t.Fatalf("budget used %d, but %d bytes are retained", b.Used(), retained)
+	}
+}
@@ -1,11 +1,31 @@
 package simpleCache
 
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
 // ByteView 作为存储在缓存中的一种 Value
 // 特性是只读
+// 开启压缩时b中保存的是压缩后的数据, 在读取内容时才进行解压
 type ByteView struct {
-	b []byte // 可以支持如图片、视频等二进制数据
+	b        []byte    // 可以支持如图片、视频等二进制数据
+	encoding string    // b的压缩算法, 为空表示没有压缩
+	version  string    // 数据的版本, 可以用作ETag
+	ctime    time.Time // 从数据源载入的时间
+	expire   time.Time // 过期时间, 零值表示永不过期
+	origin   string    // 从数据源载入这份数据的节点
+	ctype    string    // 通过Set写入时指定的Content-Type, 为空表示未知
+	hits     *int64    // 载入后的命中次数, 所有拷贝共享, 用于判断是否需要提前刷新
+	stale    bool      // 重新加载失败时返回的陈旧数据
 }
 
+// Len 数据实际占用的字节数, 压缩时为压缩后的大小
 func (v ByteView) Len() int {
 	return len(v.b)
 }
@@ -13,12 +33,109 @@ func (v ByteView) Len() int {
 // string天然不可修改,不用特殊处理
 // 将数据看作string处理,便于缓存的字符串数据的使用
 func (v ByteView) String() string {
-	return string(v.b)
+	return string(v.bytes())
 }
 
 // ByteSlice 为了实现只读,需要进行深拷贝
 func (v ByteView) ByteSlice() []byte {
-	data := make([]byte, v.Len())
-	copy(data, v.b)
+	data := v.bytes()
+	if v.encoding != "" {
+		// 解压得到的已经是新的内存了
+		return data
+	}
+	res := make([]byte, len(data))
+	copy(res, data)
+	return res
+}
+
+// Encoding 数据在缓存中的压缩算法, 为空表示没有压缩
+func (v ByteView) Encoding() string {
+	return v.encoding
+}
+
+// bytes 返回解压后的数据, 没有压缩时直接返回b, 调用方不能修改
+// 解压不做缓存, 否则解压后的数据会一直占用内存, 压缩也就失去了意义
+func (v ByteView) bytes() []byte {
+	if v.encoding == "" {
+		return v.b
+	}
+	data, err := v.decompress()
+	if err != nil {
+		log.Printf("decompress %s data failed: %v", v.encoding, err)
+		return nil
+	}
 	return data
 }
+
+func (v ByteView) decompress() ([]byte, error) {
+	c, ok := getCompressor(v.encoding)
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", v.encoding)
+	}
+	return c.Decompress(v.b)
+}
+
+// reader 以流的方式读取解压后的数据, 压缩算法支持时边读边解压
+func (v ByteView) reader() (io.ReadCloser, error) {
+	if v.encoding == "" {
+		return io.NopCloser(bytes.NewReader(v.b)), nil
+	}
+	c, ok := getCompressor(v.encoding)
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", v.encoding)
+	}
+	if sd, ok := c.(StreamDecompressor); ok {
+		return sd.DecompressReader(bytes.NewReader(v.b))
+	}
+	data, err := c.Decompress(v.b)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Version 数据的版本, 由数据内容计算得到, 内容相同的数据版本相同
+func (v ByteView) Version() string {
+	return v.version
+}
+
+// CreateTime 数据从数据源载入的时间
+func (v ByteView) CreateTime() time.Time {
+	return v.ctime
+}
+
+// Expire 数据的过期时间, 零值表示永不过期
+func (v ByteView) Expire() time.Time {
+	return v.expire
+}
+
+// Origin 从数据源载入这份数据的节点
+func (v ByteView) Origin() string {
+	return v.origin
+}
+
+// ContentType 通过Set写入数据时指定的Content-Type, 为空表示未知
+func (v ByteView) ContentType() string {
+	return v.ctype
+}
+
+// Stale 数据是否是重新加载失败后返回的陈旧数据
+// 只有开启了WithStaleIfError才可能返回true
+func (v ByteView) Stale() bool {
+	return v.stale
+}
+
+// expired 判断数据在now时刻是否已经过期
+func (v ByteView) expired(now time.Time) bool {
+	return !v.expire.IsZero() && !now.Before(v.expire)
+}
+
+// versionLen versionOf生成的版本最长的字符数
+const versionLen = 16
+
+// versionOf 用数据内容的哈希值作为版本
+func versionOf(b []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -1,42 +1,572 @@
 package simpleCache
 
 import (
+	"encoding/binary"
+	"log"
+	"simpleCache/disk"
 	"simpleCache/lru"
 	"sync"
+	"time"
+	"unsafe"
 )
 
+// entryOverhead 每条数据除key和value之外的内存开销:
+// lru内部的结构、装箱后的ByteView、命中计数和数据版本的字符串
+var entryOverhead = lru.EntryOverhead + int64(unsafe.Sizeof(ByteView{})+unsafe.Sizeof(int64(0))) + versionLen
+
 // 其实就是对lru中的cache再包装了一层,增加了并发访问控制
 // 并且将cache中的value指定为了byteView
 type cache struct {
-	mu         sync.Mutex // 实现并发控制
-	lru        *lru.Cache // 实际存储信息的位置
-	cacheBytes int64      // 控制缓存空间的大小, 0时不进行限制
+	mu         sync.RWMutex // 实现并发控制, 只有sharedReads时才会用到读锁
+	mem        storage      // 实际存储信息的位置, 默认是lru
+	cacheBytes int64        // 控制缓存空间的大小, 0时不进行限制
+	disk       *disk.Store  // 可选的磁盘二级缓存, 从lru中淘汰的数据会写到这里
+
+	// stale-if-error使用的宽限区, 保存过期或被淘汰的数据
+	// 数据源出错时可以用其中不超过maxStale的数据兜底
+	grace    *lru.Cache
+	maxStale time.Duration
+
+	closed bool // close之后不再保存任何数据
+
+	// 为true时缓存大小包含每条数据的固定开销, 而不只是key和value的长度
+	countOverhead bool
+	// 为true时使用slab存储引擎, 缓冲区按cacheBytes一次性分配
+	useSlab bool
+	// 为true时命中只需要读锁, 见WithConcurrentReads
+	sharedReads bool
+
+	// 可选的共享内存预算, reported是已经计入预算的字节数
+	budget   *Budget
+	reserve  int64 // 保留额度, 内存用量不超过它时不会因为预算被淘汰
+	weight   int   // 权重, 越大的数据在预算中保留得越久
+	reported int64
+
+	// 数据离开内存时的通知, 由注册了Listener的group设置, 调用时持有mu
+	notify func(key string, value ByteView, reason EvictReason)
+	// purge期间的淘汰按EvictRemoved通知, 并且直接丢弃
+	purging bool
+	// 预算不足时淘汰的数据不进入宽限区, 宽限区不计入预算, 否则内存并没有被释放
+	reclaiming bool
+	// 内存压力下淘汰的数据直接丢弃, 既不进入宽限区也不写入磁盘
+	shrinking bool
+}
+
+func (c *cache) lazyInit() {
+	if c.mem == nil {
+		var onEvict func(key string, view ByteView)
+		if c.disk != nil || c.maxStale > 0 || c.notify != nil {
+			onEvict = c.evicted
+		}
+		var clock func() int64
+		if c.budget != nil {
+			clock = c.budget.now
+		}
+		if c.useSlab {
+			c.mem = newSlabStorage(c.cacheBytes, clock, onEvict)
+		} else {
+			var overhead int64
+			if c.countOverhead {
+				overhead = entryOverhead
+			}
+			c.mem = newLRUStorage(c.cacheBytes, overhead, clock, onEvict)
+		}
+	}
+	if c.grace == nil && c.maxStale > 0 {
+		c.grace = lru.New(c.graceLimit(), nil)
+		if c.countOverhead {
+			c.grace.Overhead = entryOverhead
+		}
+	}
 }
 
 func (c *cache) get(key string) (ByteView, bool) {
+	if c.sharedReads {
+		if view, ok := c.getShared(key); ok {
+			return view, true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	if c.closed {
+		return ByteView{}, false
+	}
+	c.lazyInit()
+
+	view, ok := c.mem.Get(key)
+	if !ok {
+		return c.getFromDisk(key)
+	}
+
+	// 过期的数据直接删掉,当作未命中处理
+	if view.expired(time.Now()) {
+		c.mem.Remove(key)
+		c.notifyEvict(key, view, EvictExpired)
+		c.retire(key, view)
+		return ByteView{}, false
+	}
+	return view, true
+}
+
+// getShared 在读锁下查找内存中未过期的数据, 不会修改数据的位置
+// 未命中、已过期或存储引擎不支持时返回false, 由get加锁后再处理
+func (c *cache) getShared(key string) (ByteView, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mem, ok := c.mem.(sharedReader)
+	if !ok {
+		return ByteView{}, false
+	}
+	view, ok := mem.GetShared(key)
+	if !ok || view.expired(time.Now()) {
+		return ByteView{}, false
+	}
+	return view, true
+}
+
+// touch 修改未过期数据的过期时间, 零值表示永不过期
+func (c *cache) touch(key string, expire time.Time) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.report()
 
-	if c.lru == nil {
-		// todo:这里onEvict没有给设置的方法
-		c.lru = lru.New(c.cacheBytes, nil)
+	if c.closed {
+		return false
 	}
+	c.lazyInit()
 
-	val, ok := c.lru.Get(key)
+	view, ok := c.mem.Get(key)
 	if !ok {
+		if view, ok = c.getFromDisk(key); !ok {
+			return false
+		}
+	}
+	if view.expired(time.Now()) {
+		return false
+	}
+
+	view.expire = expire
+	c.mem.Add(key, view)
+	return true
+}
+
+// remove 从所有层级中删除数据, 删除的数据不会进入宽限区
+// 返回内存或磁盘中是否存在这条数据
+func (c *cache) remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	found := false
+	if c.mem != nil {
+		if view, ok := c.mem.Peek(key); ok {
+			found = true
+			c.mem.Remove(key)
+			c.notifyEvict(key, view, EvictRemoved)
+		}
+	}
+	if c.grace != nil {
+		c.grace.Remove(key)
+	}
+	if c.disk != nil {
+		if _, _, ok := c.disk.Get(key); ok {
+			found = true
+		}
+		if err := c.disk.Delete(key); err != nil {
+			log.Printf("delete key %s from disk failed: %v", key, err)
+		}
+	}
+	return found
+}
+
+// peek 查找内存中未过期的数据, 不改变淘汰顺序, 也不查询磁盘缓存
+func (c *cache) peek(key string) (ByteView, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mem == nil {
 		return ByteView{}, false
 	}
-	return val.(ByteView), true
+	view, ok := c.mem.Peek(key)
+	if !ok || view.expired(time.Now()) {
+		return ByteView{}, false
+	}
+	return view, true
+}
+
+// purge 和remove一样从所有层级中删除全部数据, 删除的数据不会进入宽限区
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	if c.mem != nil {
+		c.purging = true
+		c.mem.Purge()
+		c.purging = false
+	}
+	if c.grace != nil {
+		c.grace.Purge()
+	}
+	if c.disk != nil {
+		if err := c.disk.Purge(); err != nil {
+			log.Printf("purge disk cache failed: %v", err)
+		}
+	}
+}
+
+// stats 内存中的数据条数和占用的字节数
+func (c *cache) stats() (items int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mem == nil {
+		return 0, 0
+	}
+	return c.mem.Len(), c.mem.Bytes()
 }
 
 func (c *cache) add(key string, value ByteView) {
+	c.put(key, value)
+	// 释放锁之后再回收, 回收时会锁住其它group的cache
+	if c.budget != nil {
+		c.budget.reclaim()
+	}
+}
+
+func (c *cache) put(key string, value ByteView) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	if c.closed {
+		return
+	}
+	c.lazyInit()
+
+	// 保证同一个key只存在于其中一级缓存
+	if c.grace != nil {
+		c.grace.Remove(key)
+	}
+	if c.disk != nil {
+		if err := c.disk.Delete(key); err != nil {
+			log.Printf("delete key %s from disk failed: %v", key, err)
+		}
+	}
+	if c.notify != nil {
+		if old, ok := c.mem.Peek(key); ok {
+			c.notifyEvict(key, old, EvictReplaced)
+		}
+	}
+	c.mem.Add(key, value)
+}
+
+// resize 修改内存和宽限区的大小上限, 超出的数据立即被淘汰
+func (c *cache) resize(cacheBytes int64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.report()
+
+	c.cacheBytes = cacheBytes
+	if c.mem != nil {
+		c.mem.Resize(cacheBytes)
+	}
+	if c.grace != nil {
+		c.grace.Resize(c.graceLimit())
+	}
+}
+
+// graceLimit 宽限区和内存缓存的上限相同, 只受预算限制的group使用预算的大小
+func (c *cache) graceLimit() int64 {
+	if c.cacheBytes == 0 && c.budget != nil {
+		return c.budget.Limit()
+	}
+	return c.cacheBytes
+}
+
+// close 丢弃内存中的数据并关闭磁盘缓存
+func (c *cache) close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mem = nil
+	c.grace = nil
+	c.report()
+	d := c.disk
+	c.disk = nil
+	c.mu.Unlock()
+
+	// 回收内存时先锁住预算再锁住成员, 所以要在释放c.mu之后离开预算
+	if c.budget != nil {
+		c.budget.leave(c)
+	}
+	if d == nil {
+		return nil
+	}
+	return d.Close()
+}
+
+// report 把内存用量的变化计入共享预算, 调用时需要持有c.mu
+func (c *cache) report() {
+	if c.budget == nil {
+		return
+	}
+	var n int64
+	if c.mem != nil {
+		n = c.mem.Bytes()
+	}
+	if d := n - c.reported; d != 0 {
+		c.budget.add(d)
+		c.reported = n
+	}
+}
+
+// oldest 最旧数据的访问时间和内存用量, 供Budget挑选淘汰对象
+func (c *cache) oldest() (atime int64, bytes int64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mem == nil {
+		return 0, 0, false
+	}
+	atime, ok = c.mem.OldestAccess()
+	return atime, c.mem.Bytes(), ok
+}
+
+// shrink 内存和宽限区都从最旧的数据开始丢弃, 直到各自减少fraction, 返回释放的字节数
+// 丢弃的数据不会转移到宽限区或磁盘
+func (c *cache) shrink(fraction float64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	if c.mem == nil {
+		return 0
+	}
+	before := c.mem.Bytes()
+	target := before - int64(float64(before)*fraction)
+	c.shrinking = true
+	for c.mem.Len() > 0 && c.mem.Bytes() > target {
+		c.mem.RemoveOldest()
+	}
+	c.shrinking = false
+	freed := before - c.mem.Bytes()
+
+	if c.grace != nil {
+		before = c.grace.Bytes()
+		target = before - int64(float64(before)*fraction)
+		for c.grace.Len() > 0 && c.grace.Bytes() > target {
+			c.grace.RemoveOldest()
+		}
+		freed += before - c.grace.Bytes()
+	}
+	return freed
+}
+
+// evictOldest 因为预算不足淘汰最旧的一条数据, 可以写入磁盘, 但不会进入宽限区
+func (c *cache) evictOldest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.report()
+
+	if c.mem != nil {
+		c.reclaiming = true
+		c.mem.RemoveOldest()
+		c.reclaiming = false
+	}
+}
+
+// getFromDisk 内存未命中时查询磁盘缓存, 命中后把数据提升回内存
+func (c *cache) getFromDisk(key string) (ByteView, bool) {
+	if c.disk == nil {
+		return ByteView{}, false
+	}
+
+	data, expire, ok := c.disk.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	view, ok := decodeDiskView(data, expire)
+	if !ok {
+		_ = c.disk.Delete(key)
+		return ByteView{}, false
+	}
+
+	if err := c.disk.Delete(key); err != nil {
+		log.Printf("delete key %s from disk failed: %v", key, err)
+	}
+	c.mem.Add(key, view)
+	return view, true
+}
+
+// evicted 作为存储引擎的OnEvict
+// 未过期的数据优先写入磁盘, 否则放进宽限区, 预算不足时的淘汰不放进宽限区
+func (c *cache) evicted(key string, view ByteView) {
+	// purge删除的数据不写入磁盘和宽限区
+	if c.purging {
+		c.notifyEvict(key, view, EvictRemoved)
+		return
+	}
+
+	expired := view.expired(time.Now())
+	switch {
+	case expired:
+		c.notifyEvict(key, view, EvictExpired)
+	default:
+		c.notifyEvict(key, view, EvictCapacity)
+	}
+
+	if c.shrinking {
+		return
+	}
+	if c.disk != nil && !expired {
+		err := c.disk.Put(key, encodeDiskView(view), view.expire)
+		if err == nil {
+			return
+		}
+		log.Printf("spill key %s to disk failed: %v", key, err)
+	}
+	if c.reclaiming {
+		return
+	}
+	c.retire(key, view)
+}
+
+func (c *cache) notifyEvict(key string, view ByteView, reason EvictReason) {
+	if c.notify != nil {
+		c.notify(key, view, reason)
+	}
+}
+
+// retire 把过期或被淘汰的数据放进宽限区
+func (c *cache) retire(key string, view ByteView) {
+	if c.grace == nil {
+		return
+	}
+
+	// 有过期时间的数据从过期开始计算陈旧时间, 否则从淘汰时开始计算
+	now := time.Now()
+	until := now.Add(c.maxStale)
+	if !view.expire.IsZero() && view.expire.Before(now) {
+		until = view.expire.Add(c.maxStale)
+	}
+	c.grace.Add(key, graceEntry{view: view, until: until})
+}
+
+// getStale 从宽限区中取出不超过maxStale的数据, 返回的数据会被标记为stale
+func (c *cache) getStale(key string) (ByteView, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.grace == nil {
+		return ByteView{}, false
+	}
+	val, ok := c.grace.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	e := val.(graceEntry)
+	if !time.Now().Before(e.until) {
+		c.grace.Remove(key)
+		return ByteView{}, false
+	}
+
+	view := e.view
+	view.stale = true
+	return view, true
+}
+
+// entries 按从旧到新的顺序导出所有未过期的数据
+func (c *cache) entries() []cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mem == nil {
+		return nil
+	}
+
+	now := time.Now()
+	res := make([]cacheEntry, 0, c.mem.Len())
+	c.mem.Range(func(key string, view ByteView) bool {
+		if !view.expired(now) {
+			res = append(res, cacheEntry{key: key, value: view})
+		}
+		return true
+	})
+	return res
+}
+
+// cacheEntry 导出缓存内容时使用的键值对
+type cacheEntry struct {
+	key   string
+	value ByteView
+}
+
+// graceEntry 宽限区中的数据, until之后不再可用
+type graceEntry struct {
+	view  ByteView
+	until time.Time
+}
+
+func (e graceEntry) Len() int {
+	return e.view.Len()
+}
+
+// 磁盘中的数据格式:
+// format(1字节) | ctime(varint) | verLen(uvarint) | 数据版本 | originLen(uvarint) | origin |
+// encLen(uvarint) | 压缩算法 | ctLen(uvarint) | Content-Type | value
+// 过期时间由disk.Store单独保存
+// format不一致的数据无法读取, 按缓存未命中处理
+const diskViewFormat = 1
+
+func encodeDiskView(v ByteView) []byte {
+	fields := []string{v.version, v.origin, v.encoding, v.ctype}
+	size := 1 + (len(fields)+1)*binary.MaxVarintLen64 + len(v.b)
+	for _, field := range fields {
+		size += len(field)
+	}
+	buf := make([]byte, size)
+	buf[0] = diskViewFormat
+	n := 1
+	n += binary.PutVarint(buf[n:], unixNano(v.ctime))
+	for _, field := range fields {
+		n += binary.PutUvarint(buf[n:], uint64(len(field)))
+		n += copy(buf[n:], field)
+	}
+	n += copy(buf[n:], v.b)
+	return buf[:n]
+}
+
+func decodeDiskView(data []byte, expire time.Time) (ByteView, bool) {
+	if len(data) == 0 || data[0] != diskViewFormat {
+		return ByteView{}, false
+	}
+	ctime, n := binary.Varint(data[1:])
+	if n <= 0 {
+		return ByteView{}, false
+	}
+	data = data[1+n:]
 
-	if c.lru == nil {
-		// todo:这里onEvict没有给设置的方法
-		c.lru = lru.New(c.cacheBytes, nil)
+	var fields [4]string
+	for i := range fields {
+		l, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < l {
+			return ByteView{}, false
+		}
+		fields[i] = string(data[n : n+int(l)])
+		data = data[n+int(l):]
 	}
 
-	c.lru.Add(key, value)
+	return ByteView{
+		b:        data,
+		encoding: fields[2],
+		version:  fields[0],
+		ctime:    fromUnixNano(ctime),
+		expire:   expire,
+		origin:   fields[1],
+		ctype:    fields[3],
+	}, true
 }
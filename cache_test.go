@@ -0,0 +1,101 @@
+package simpleCache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentReads(t *testing.T) {
+	reg := NewRegistry()
+	var loads int64
+	g := newTestGroup(t, reg, "concurrent", 64, GetterFunc(
+		func(key string) ([]byte, error) {
+			atomic.AddInt64(&loads, 1)
+			return []byte("v" + key), nil
+		}), WithConcurrentReads(), WithTTL(20*time.Millisecond))
+	defer g.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i + j) % 4)
+				if view, err := g.Get(key); err != nil || view.String() != "v"+key {
+					t.Errorf("get %s got %v, %v", key, view, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(&loads); n < 4 || n > 100 {
+		t.Fatalf("4 hot keys loaded %d times", n)
+	}
+
+	// 过期的数据不会从读锁的路径返回
+	time.Sleep(30 * time.Millisecond)
+	before := atomic.LoadInt64(&loads)
+	if _, err := g.Get("0"); err != nil || atomic.LoadInt64(&loads) != before+1 {
+		t.Fatalf("expired key should be loaded again")
+	}
+
+	// 经常访问的数据在淘汰时会被保留
+	for i := 0; i < 20; i++ {
+		_, _ = g.Get("hot")
+		_, _ = g.Get("cold" + strconv.Itoa(i))
+	}
+	before = atomic.LoadInt64(&loads)
+	if _, _ = g.Get("hot"); atomic.LoadInt64(&loads) != before {
+		t.Fatalf("hot key should not be evicted")
+	}
+}
+
+var errBenchMiss = errors.New("miss")
+
+// BenchmarkGroupGetParallel 命中率约97%时并发读取的性能
+func BenchmarkGroupGetParallel(b *testing.B) {
+	const keys = 1024
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"mutex", nil},
+		{"concurrent-reads", []Option{WithConcurrentReads()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			reg := NewRegistry()
+			g := newTestGroup(b, reg, "bench", 0, GetterFunc(
+				func(key string) ([]byte, error) {
+					return nil, errBenchMiss
+				}), bc.opts...)
+			defer g.Close()
+			names := make([]string, keys+keys/32)
+			for i := range names {
+				names[i] = fmt.Sprintf("key-%d", i)
+				if i < keys {
+					_ = g.Set(names[i], []byte(names[i]))
+				}
+			}
+
+			var seed uint32
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				x := atomic.AddUint32(&seed, 0x9e3779b9) | 1
+				for pb.Next() {
+					x ^= x << 13
+					x ^= x >> 17
+					x ^= x << 5
+					_, _ = g.Get(names[x%uint32(len(names))])
+				}
+			})
+		})
+	}
+}
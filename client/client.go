@@ -0,0 +1,241 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"simpleCache"
+	"simpleCache/consistenthash"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* 访问simpleCache集群的客户端, 不需要在本地创建Group
+ * 客户端和集群用同样的方式构建一致性哈希环, 每个请求直接发给负责这个key的节点
+ * 节点需要提供simpleCache.APIHandler的接口
+ */
+
+const (
+	defaultTimeout = 5 * time.Second
+	defaultRetries = 2
+	defaultBackoff = 50 * time.Millisecond
+
+	// GetMany同时进行的请求数
+	getManyConcurrency = 16
+)
+
+var ErrNotFound = errors.New("client: key not found")
+
+// Client 并发安全
+type Client struct {
+	ring     *consistenthash.Map
+	endpoint func(peer string) string
+	http     *http.Client
+	timeout  *time.Duration // 为nil时不改动http的超时
+	retries  int
+	backoff  time.Duration
+}
+
+// Option 创建Client时的额外配置
+type Option func(c *Client)
+
+// WithTimeout 单次HTTP请求的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = &timeout
+	}
+}
+
+// WithRetries 网络错误或5xx时的重试次数, 每次重试前等待backoff, 并且逐次翻倍
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithHTTPClient 使用自定义的http.Client
+// hc可能被其它地方共用, WithTimeout只作用于Client内部的拷贝
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithEndpoint 节点在哈希环中的名字和API地址不同时, 用fn把名字转换成API地址
+// 默认两者相同, 即节点在同一个地址上同时提供HttpPool和APIHandler
+func WithEndpoint(fn func(peer string) string) Option {
+	return func(c *Client) {
+		c.endpoint = fn
+	}
+}
+
+// New peers是集群中各个节点在哈希环中的名字, 需要和HttpPool.Set的参数一致
+func New(peers []string, opts ...Option) *Client {
+	c := &Client{
+		ring:     consistenthash.New(simpleCache.DefaultReplicas, nil),
+		endpoint: func(peer string) string { return peer },
+		http:     &http.Client{Timeout: defaultTimeout},
+		retries:  defaultRetries,
+		backoff:  defaultBackoff,
+	}
+	c.ring.Add(peers...)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout != nil {
+		hc := *c.http
+		hc.Timeout = *c.timeout
+		c.http = &hc
+	}
+	return c
+}
+
+// Owner 负责key的节点
+func (c *Client) Owner(key string) string {
+	return c.ring.Get(key)
+}
+
+// Get 从负责key的节点读取数据, 数据不存在时返回ErrNotFound
+func (c *Client) Get(ctx context.Context, group, key string) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, http.MethodGet, group, key, nil, nil, func(resp *http.Response) error {
+		var err error
+		value, err = io.ReadAll(resp.Body)
+		return err
+	})
+	return value, err
+}
+
+// GetMany 并发读取多个key, 不存在的key不会出现在结果中
+// 任意一个key出现其他错误时返回错误
+func (c *Client) GetMany(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		res      = make(map[string][]byte, len(keys))
+		firstErr error
+		sem      = make(chan struct{}, getManyConcurrency)
+	)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			value, err := c.Get(ctx, group, key)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				res[key] = value
+			case errors.Is(err, ErrNotFound):
+			case firstErr == nil:
+				firstErr = err
+				cancel()
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return res, nil
+}
+
+// Set 把数据写入负责key的节点, ttl为0时使用group的默认配置
+func (c *Client) Set(ctx context.Context, group, key string, value []byte, ttl time.Duration) error {
+	query := url.Values{}
+	if ttl > 0 {
+		query.Set("ttl", ttl.String())
+	}
+	return c.do(ctx, http.MethodPut, group, key, query, value, nil)
+}
+
+// Delete 从负责key的节点删除数据
+func (c *Client) Delete(ctx context.Context, group, key string) error {
+	return c.do(ctx, http.MethodDelete, group, key, nil, nil, nil)
+}
+
+// do 发送请求, 网络错误和5xx会进行重试
+func (c *Client) do(ctx context.Context, method, group, key string, query url.Values, body []byte, handle func(resp *http.Response) error) error {
+	if key == "" {
+		return errors.New("client: empty key")
+	}
+	peer := c.ring.Get(key)
+	if peer == "" {
+		return errors.New("client: no peers")
+	}
+	target := fmt.Sprintf("%s/groups/%s/keys/%s",
+		strings.TrimSuffix(c.endpoint(peer), "/"), url.PathEscape(group), url.PathEscape(key))
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.backoff
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		retry, err := c.once(ctx, method, target, body, handle)
+		if err == nil || !retry {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// once 发送一次请求, 返回错误是否值得重试
+func (c *Client) once(ctx context.Context, method, target string, body []byte, handle func(resp *http.Response) error) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method == http.MethodGet:
+		return false, ErrNotFound
+	case resp.StatusCode >= 500:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return true, fmt.Errorf("client: %s %s failed with status %d: %s",
+			method, target, resp.StatusCode, strings.TrimSpace(string(msg)))
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("client: %s %s failed with status %d: %s",
+			method, target, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if handle != nil {
+		return false, handle(resp)
+	}
+	return false, nil
+}
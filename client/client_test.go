@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"simpleCache"
+	"simpleCache/consistenthash"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var db = map[string]string{
+	"Tom":  "630",
+	"Jack": "589",
+	"Sam":  "567",
+}
+
+// node 统计收到的请求, failures不为0时先返回对应次数的503
+type node struct {
+	handler  http.Handler
+	requests int64
+	failures int64
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&n.requests, 1)
+	if atomic.AddInt64(&n.failures, -1) >= 0 {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	n.handler.ServeHTTP(w, req)
+}
+
+func TestClient(t *testing.T) {
+	reg := simpleCache.NewRegistry()
+	_, err := reg.NewGroup("client-scores", 2<<10, simpleCache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, simpleCache.ErrNotFound)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make(map[string]*node)
+	var peers []string
+	for i := 0; i < 3; i++ {
+		n := &node{handler: reg.NewAPIHandler()}
+		server := httptest.NewServer(n)
+		defer server.Close()
+		nodes[server.URL] = n
+		peers = append(peers, server.URL)
+	}
+	c := New(peers, WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+
+	// 和HttpPool使用同样的哈希环
+	ring := consistenthash.New(simpleCache.DefaultReplicas, nil)
+	ring.Add(peers...)
+	for k := range db {
+		if c.Owner(k) != ring.Get(k) {
+			t.Fatalf("owner of %s is different from the cluster", k)
+		}
+	}
+
+	owner := nodes[c.Owner("Tom")]
+	if v, err := c.Get(ctx, "client-scores", "Tom"); err != nil || string(v) != "630" {
+		t.Fatalf("get Tom got %s %v", v, err)
+	}
+	if atomic.LoadInt64(&owner.requests) != 1 {
+		t.Fatal("request should be sent to the owner of the key")
+	}
+	if _, err := c.Get(ctx, "client-scores", "kkk"); err != ErrNotFound {
+		t.Fatalf("missing key should return ErrNotFound, got %v", err)
+	}
+
+	// 失败后重试
+	atomic.StoreInt64(&owner.failures, 2)
+	if v, err := c.Get(ctx, "client-scores", "Tom"); err != nil || string(v) != "630" {
+		t.Fatalf("get Tom should succeed after retries, got %v", err)
+	}
+	atomic.StoreInt64(&owner.failures, 3)
+	if _, err := c.Get(ctx, "client-scores", "Tom"); err == nil {
+		t.Fatal("get Tom should fail when retries are exhausted")
+	}
+	atomic.StoreInt64(&owner.failures, 0)
+
+	if err := c.Set(ctx, "client-scores", "Amy", []byte("700"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.GetMany(ctx, "client-scores", []string{"Tom", "Amy", "kkk"})
+	if err != nil || len(res) != 2 || string(res["Tom"]) != "630" || string(res["Amy"]) != "700" {
+		t.Fatalf("get many got %v %v", res, err)
+	}
+	if err = c.Delete(ctx, "client-scores", "Amy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get(ctx, "client-scores", "Amy"); err != ErrNotFound {
+		t.Fatalf("deleted key should return ErrNotFound, got %v", err)
+	}
+}
+
+func TestWithHTTPClientTimeout(t *testing.T) {
+	hc := &http.Client{Timeout: 3 * time.Second}
+	peers := []string{"http://node1"}
+
+	c := New(peers, WithTimeout(time.Second), WithHTTPClient(hc))
+	if c.http.Timeout != time.Second {
+		t.Fatalf("client timeout is %v, want 1s", c.http.Timeout)
+	}
+	if hc.Timeout != 3*time.Second {
+		t.Fatalf("caller's http.Client was changed to %v", hc.Timeout)
+	}
+	if c = New(peers, WithHTTPClient(hc)); c.http != hc {
+		t.Fatal("http.Client should be shared when no timeout is given")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"simpleCache"
+	"simpleCache/getter"
+	"time"
+)
+
+// backend 一种数据源, 返回的io.Closer在退出时关闭, 可以为nil
+type backend struct {
+	validate func(cfg BackendConfig) error
+	create   func(cfg BackendConfig) (simpleCache.Getter, io.Closer, error)
+}
+
+// backends 按配置中的getter.type创建Getter
+var backends = map[string]backend{
+	// none 没有数据源, 数据只能通过API写入
+	"none": {
+		create: func(cfg BackendConfig) (simpleCache.Getter, io.Closer, error) {
+			return simpleCache.GetterFunc(func(key string) ([]byte, error) {
+				return nil, fmt.Errorf("%s: %w", key, simpleCache.ErrNotFound)
+			}), nil, nil
+		},
+	},
+	// static 使用配置文件中的固定数据, 便于测试和演示
+	"static": {
+		create: func(cfg BackendConfig) (simpleCache.Getter, io.Closer, error) {
+			values := cfg.Values
+			return simpleCache.GetterFunc(func(key string) ([]byte, error) {
+				if v, ok := values[key]; ok {
+					return []byte(v), nil
+				}
+				return nil, fmt.Errorf("%s: %w", key, simpleCache.ErrNotFound)
+			}), nil, nil
+		},
+	},
+	// http 从源站 {origin}/{key} 加载数据
+	"http": {
+		validate: func(cfg BackendConfig) error {
+			if cfg.Origin == "" {
+				return errors.New("origin is required")
+			}
+			return nil
+		},
+		create: func(cfg BackendConfig) (simpleCache.Getter, io.Closer, error) {
+			var opts []getter.HTTPOption
+			if cfg.Timeout > 0 {
+				opts = append(opts, getter.WithTimeout(time.Duration(cfg.Timeout)))
+			}
+			if cfg.MaxBodySize > 0 {
+				opts = append(opts, getter.WithMaxBodySize(cfg.MaxBodySize))
+			}
+			for k, v := range cfg.Headers {
+				opts = append(opts, getter.WithHeader(k, v))
+			}
+			return getter.NewHTTP(cfg.Origin, opts...), nil, nil
+		},
+	},
+	// sql 用key作为参数执行query, 驱动需要编译进来, 见drivers.go
+	"sql": {
+		validate: func(cfg BackendConfig) error {
+			if cfg.Driver == "" || cfg.DSN == "" || cfg.Query == "" {
+				return errors.New("driver, dsn and query are required")
+			}
+			for _, d := range sql.Drivers() {
+				if d == cfg.Driver {
+					return nil
+				}
+			}
+			return fmt.Errorf("sql driver %q is not compiled in, available: %v", cfg.Driver, sql.Drivers())
+		},
+		create: func(cfg BackendConfig) (simpleCache.Getter, io.Closer, error) {
+			db, err := sql.Open(cfg.Driver, cfg.DSN)
+			if err != nil {
+				return nil, nil, err
+			}
+			var opts []getter.SQLOption
+			if cfg.Timeout > 0 {
+				opts = append(opts, getter.WithQueryTimeout(time.Duration(cfg.Timeout)))
+			}
+			return getter.NewSQL(db, cfg.Query, opts...), db, nil
+		},
+	},
+}
+
+func (cfg BackendConfig) validate() error {
+	b, ok := backends[cfg.Type]
+	if !ok {
+		return fmt.Errorf("unknown getter type %q", cfg.Type)
+	}
+	if b.validate == nil {
+		return nil
+	}
+	return b.validate(cfg)
+}
+
+func newGetter(cfg BackendConfig) (simpleCache.Getter, io.Closer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, nil, err
+	}
+	return backends[cfg.Type].create(cfg)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 服务端的配置文件, 根据扩展名解析json、yaml或toml
+type Config struct {
+	Listen         string        `json:"listen" yaml:"listen" toml:"listen"`                            // 节点间通信和REST接口的监听地址
+	Self           string        `json:"self" yaml:"self" toml:"self"`                                  // 本节点在哈希环中的名字, 如http://10.0.0.1:8001
+	Peers          []string      `json:"peers" yaml:"peers" toml:"peers"`                               // 集群中的所有节点, 包括自己
+	RESPListen     string        `json:"resp_listen" yaml:"resp_listen" toml:"resp_listen"`             // 可选, redis协议的监听地址
+	MemcacheListen string        `json:"memcache_listen" yaml:"memcache_listen" toml:"memcache_listen"` // 可选, memcached协议的监听地址
+	ShutdownWait   Duration      `json:"shutdown_wait" yaml:"shutdown_wait" toml:"shutdown_wait"`       // 优雅退出时等待请求处理完的时间
+	MemoryBudget   int64         `json:"memory_budget" yaml:"memory_budget" toml:"memory_budget"`       // 可选, 所有group共享的内存预算
+	MemoryMonitor  bool          `json:"memory_monitor" yaml:"memory_monitor" toml:"memory_monitor"`    // 进程内存接近上限时逐步缩小缓存
+	MemoryLimit    int64         `json:"memory_limit" yaml:"memory_limit" toml:"memory_limit"`          // 进程的内存上限, 0表示使用GOMEMLIMIT
+	Groups         []GroupConfig `json:"groups" yaml:"groups" toml:"groups"`
+}
+
+// GroupConfig 一个group的配置
+type GroupConfig struct {
+	Name             string        `json:"name" yaml:"name" toml:"name"`
+	CacheBytes       int64         `json:"cache_bytes" yaml:"cache_bytes" toml:"cache_bytes"`
+	TTL              Duration      `json:"ttl" yaml:"ttl" toml:"ttl"`
+	SoftTTL          Duration      `json:"soft_ttl" yaml:"soft_ttl" toml:"soft_ttl"`
+	StaleIfError     Duration      `json:"stale_if_error" yaml:"stale_if_error" toml:"stale_if_error"`
+	Snapshot         string        `json:"snapshot" yaml:"snapshot" toml:"snapshot"`
+	SnapshotInterval Duration      `json:"snapshot_interval" yaml:"snapshot_interval" toml:"snapshot_interval"`
+	DiskDir          string        `json:"disk_dir" yaml:"disk_dir" toml:"disk_dir"`
+	DiskBytes        int64         `json:"disk_bytes" yaml:"disk_bytes" toml:"disk_bytes"`
+	CompressAbove    int           `json:"compress_above" yaml:"compress_above" toml:"compress_above"`       // 不小于这个大小的数据使用gzip压缩, 0表示不压缩
+	BudgetReserve    int64         `json:"budget_reserve" yaml:"budget_reserve" toml:"budget_reserve"`       // 在共享预算中的保留额度
+	BudgetWeight     int           `json:"budget_weight" yaml:"budget_weight" toml:"budget_weight"`          // 在共享预算中的权重, 默认1
+	CountOverhead    bool          `json:"count_overhead" yaml:"count_overhead" toml:"count_overhead"`       // 缓存大小计入每条数据的固定开销
+	Storage          string        `json:"storage" yaml:"storage" toml:"storage"`                            // 存储引擎, lru(默认)或slab
+	ConcurrentReads  bool          `json:"concurrent_reads" yaml:"concurrent_reads" toml:"concurrent_reads"` // 命中时只需要读锁, 淘汰顺序近似LRU
+	Getter           BackendConfig `json:"getter" yaml:"getter" toml:"getter"`
+}
+
+// BackendConfig 数据源的配置, Type决定使用哪种Getter, 其余字段按需填写
+type BackendConfig struct {
+	Type    string   `json:"type" yaml:"type" toml:"type"`          // none、static、http或sql
+	Timeout Duration `json:"timeout" yaml:"timeout" toml:"timeout"` // http请求或sql查询的超时时间
+
+	// static
+	Values map[string]string `json:"values" yaml:"values" toml:"values"` // 固定数据
+
+	// http
+	Origin      string            `json:"origin" yaml:"origin" toml:"origin"`                      // 源站地址前缀, 请求 {origin}/{key}
+	Headers     map[string]string `json:"headers" yaml:"headers" toml:"headers"`                   // 每个请求都带上的header
+	MaxBodySize int64             `json:"max_body_size" yaml:"max_body_size" toml:"max_body_size"` // 响应体的大小上限
+
+	// sql
+	Driver string `json:"driver" yaml:"driver" toml:"driver"` // database/sql驱动名, 如sqlite3
+	DSN    string `json:"dsn" yaml:"dsn" toml:"dsn"`
+	Query  string `json:"query" yaml:"query" toml:"query"` // 以key为唯一参数的查询, 取第一行第一列
+}
+
+// Duration 支持在配置文件中写"30s"、"5m"这样的时间
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// LoadConfig 读取并校验配置文件
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return nil, fmt.Errorf("unknown config format %q, use .json, .yaml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", path, err)
+	}
+	return cfg, cfg.validate()
+}
+
+func (c *Config) validate() error {
+	if c.Listen == "" {
+		return errors.New("listen is required")
+	}
+	if c.Self == "" {
+		return errors.New("self is required")
+	}
+	if len(c.Peers) == 0 {
+		c.Peers = []string{c.Self}
+	}
+	if c.MemoryBudget < 0 || c.MemoryLimit < 0 {
+		return errors.New("memory_budget and memory_limit should not be negative")
+	}
+	if c.ShutdownWait == 0 {
+		c.ShutdownWait = Duration(10 * time.Second)
+	}
+
+	names := make(map[string]bool)
+	for _, g := range c.Groups {
+		if g.Name == "" {
+			return errors.New("group name is required")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate group %s", g.Name)
+		}
+		names[g.Name] = true
+		if g.CacheBytes < 0 || g.DiskBytes < 0 || g.BudgetReserve < 0 {
+			return fmt.Errorf("group %s: byte limits should not be negative", g.Name)
+		}
+		if g.Storage != "" && g.Storage != "lru" && g.Storage != "slab" {
+			return fmt.Errorf("group %s: unknown storage %q", g.Name, g.Storage)
+		}
+		if g.Storage == "slab" && g.CacheBytes <= 0 {
+			return fmt.Errorf("group %s: slab storage needs a positive cache_bytes", g.Name)
+		}
+		if err := g.Getter.validate(); err != nil {
+			return fmt.Errorf("group %s: %v", g.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+listen: ":8001"
+self: "http://localhost:8001"
+peers: ["http://localhost:8001", "http://localhost:8002"]
+groups:
+  - name: scores
+    cache_bytes: 2048
+    ttl: 5m
+    getter:
+      type: static
+      values:
+        Tom: "630"
+`
+
+const tomlConfig = `
+listen = ":8001"
+self = "http://localhost:8001"
+peers = ["http://localhost:8001", "http://localhost:8002"]
+
+[[groups]]
+name = "scores"
+cache_bytes = 2048
+ttl = "5m"
+
+[groups.getter]
+type = "static"
+values = { Tom = "630" }
+`
+
+const jsonConfig = `{
+  "listen": ":8001",
+  "self": "http://localhost:8001",
+  "peers": ["http://localhost:8001", "http://localhost:8002"],
+  "groups": [{
+    "name": "scores",
+    "cache_bytes": 2048,
+    "ttl": "5m",
+    "getter": {"type": "static", "values": {"Tom": "630"}}
+  }]
+}`
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	want := &Config{
+		Listen:       ":8001",
+		Self:         "http://localhost:8001",
+		Peers:        []string{"http://localhost:8001", "http://localhost:8002"},
+		ShutdownWait: Duration(10 * time.Second),
+		Groups: []GroupConfig{{
+			Name:       "scores",
+			CacheBytes: 2048,
+			TTL:        Duration(5 * time.Minute),
+			Getter: BackendConfig{
+				Type:   "static",
+				Values: map[string]string{"Tom": "630"},
+			},
+		}},
+	}
+
+	for name, content := range map[string]string{
+		"simplecache.yaml": yamlConfig,
+		"simplecache.toml": tomlConfig,
+		"simplecache.json": jsonConfig,
+	} {
+		cfg, err := LoadConfig(writeConfig(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Fatalf("%s: got %+v, want %+v", name, cfg, want)
+		}
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	cases := map[string]string{
+		"no listen":        `{"self": "http://localhost:8001"}`,
+		"duplicate group":  `{"listen": ":8001", "self": "a", "groups": [{"name": "g"}, {"name": "g"}]}`,
+		"unknown getter":   `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "getter": {"type": "redis"}}]}`,
+		"invalid duration": `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "ttl": "5 minutes"}]}`,
+		"unknown storage":  `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "storage": "btree"}]}`,
+		"slab no size":     `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "storage": "slab"}]}`,
+		"http no origin":   `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "getter": {"type": "http"}}]}`,
+		"sql no query":     `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "getter": {"type": "sql", "driver": "sqlite3", "dsn": "a.db"}}]}`,
+		"sql no driver":    `{"listen": ":8001", "self": "a", "groups": [{"name": "g", "getter": {"type": "sql", "driver": "oracle", "dsn": "a", "query": "q"}}]}`,
+	}
+	for name, content := range cases {
+		if _, err := LoadConfig(writeConfig(t, "simplecache.json", content)); err == nil {
+			t.Fatalf("%s: expect error", name)
+		}
+	}
+
+	if _, err := LoadConfig(writeConfig(t, "simplecache.ini", "")); err == nil {
+		t.Fatal("expect error for unknown format")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build cgo
+
+package main
+
+// 编译进来的database/sql驱动, 需要其它数据库时在这里加上对应驱动的import
+import _ "github.com/mattn/go-sqlite3"
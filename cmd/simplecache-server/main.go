@@ -0,0 +1,332 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"simpleCache"
+	"simpleCache/memcache"
+	"simpleCache/resp"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// simplecache-server 独立运行的缓存节点, 所有设置都来自配置文件
+// 用法: simplecache-server -config simplecache.yaml
+// SIGTERM/SIGINT 优雅退出, 退出前为配置了快照的group保存快照
+// SIGHUP 重新读取配置文件, 更新集群节点, 增删group, 调整group的大小和数据源
+
+// basePath 节点间通信使用的路径前缀, 和HttpPool保持一致
+const basePath = "/_simplecache/"
+
+func main() {
+	path := flag.String("config", "simplecache.yaml", "config file (.yaml, .toml or .json)")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*path)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	s := newServer(cfg)
+	if err = s.applyGroups(cfg); err != nil {
+		log.Fatalf("create groups: %v", err)
+	}
+	if cfg.MemoryMonitor {
+		if s.monitor, err = simpleCache.NewMemoryMonitor(s.registry, cfg.MemoryLimit); err != nil {
+			log.Fatalf("memory monitor: %v", err)
+		}
+	}
+	s.start()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			log.Printf("received %s, shutting down", sig)
+			break
+		}
+		log.Printf("received SIGHUP, reloading %s", *path)
+		next, err := LoadConfig(*path)
+		if err != nil {
+			log.Printf("reload config failed, keep the old one: %v", err)
+			continue
+		}
+		s.reload(next)
+	}
+	s.shutdown()
+}
+
+// server 一个节点上运行的所有服务
+type server struct {
+	mu       sync.Mutex
+	cfg      *Config
+	registry *simpleCache.Registry
+	groups   map[string]*runningGroup
+	budget   *simpleCache.Budget // 配置了memory_budget时所有group共享
+	monitor  *simpleCache.MemoryMonitor
+
+	pool *simpleCache.HttpPool
+	http *http.Server
+	resp *resp.Server
+	mc   *memcache.Server
+}
+
+// runningGroup 正在运行的group和它的数据源
+type runningGroup struct {
+	*simpleCache.Group
+	cfg    GroupConfig
+	closer io.Closer // 数据源持有的连接, 可以为nil
+}
+
+// setGetter 替换数据源, 并关闭旧数据源的连接
+func (rg *runningGroup) setGetter(cfg BackendConfig) error {
+	getter, closer, err := newGetter(cfg)
+	if err != nil {
+		return err
+	}
+	rg.SetGetter(getter)
+	rg.closeGetter()
+	rg.closer = closer
+	rg.cfg.Getter = cfg
+	return nil
+}
+
+func (rg *runningGroup) closeGetter() {
+	if rg.closer != nil {
+		_ = rg.closer.Close()
+		rg.closer = nil
+	}
+}
+
+// close 保存快照后关闭group
+func (rg *runningGroup) close() {
+	if rg.cfg.Snapshot != "" {
+		if err := rg.SaveSnapshot(); err != nil {
+			log.Printf("save snapshot of group %s failed: %v", rg.Name(), err)
+		}
+	}
+	if err := rg.Close(); err != nil {
+		log.Printf("close group %s failed: %v", rg.Name(), err)
+	}
+	rg.closeGetter()
+}
+
+func newServer(cfg *Config) *server {
+	registry := simpleCache.NewRegistry()
+	pool := registry.NewHttpPool(cfg.Self)
+	pool.Set(cfg.Peers...)
+
+	api := registry.NewAPIHandler()
+	api.RegisterPeers(pool)
+
+	// 节点间通信和REST接口共用一个端口
+	mux := http.NewServeMux()
+	mux.Handle(basePath, pool)
+	mux.Handle("/", api)
+
+	s := &server{
+		cfg:      cfg,
+		registry: registry,
+		groups:   make(map[string]*runningGroup),
+		pool:     pool,
+		http:     &http.Server{Addr: cfg.Listen, Handler: mux},
+	}
+	if cfg.MemoryBudget > 0 {
+		s.budget = simpleCache.NewBudget(cfg.MemoryBudget)
+	}
+	if cfg.RESPListen != "" {
+		s.resp = resp.NewServerFor(registry)
+	}
+	if cfg.MemcacheListen != "" {
+		s.mc = memcache.NewServerFor(registry)
+	}
+	return s
+}
+
+// start 启动各个监听, 任何一个异常退出都会结束进程
+func (s *server) start() {
+	if s.monitor != nil {
+		s.monitor.Start()
+	}
+	go func() {
+		log.Printf("simplecache-server %s is listening at %s", s.cfg.Self, s.cfg.Listen)
+		if err := s.http.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("http server: %v", err)
+		}
+	}()
+	if s.resp != nil {
+		go func() {
+			log.Printf("resp server is listening at %s", s.cfg.RESPListen)
+			if err := s.resp.ListenAndServe(s.cfg.RESPListen); !errors.Is(err, resp.ErrServerClosed) {
+				log.Fatalf("resp server: %v", err)
+			}
+		}()
+	}
+	if s.mc != nil {
+		go func() {
+			log.Printf("memcache server is listening at %s", s.cfg.MemcacheListen)
+			if err := s.mc.ListenAndServe(s.cfg.MemcacheListen); !errors.Is(err, memcache.ErrServerClosed) {
+				log.Fatalf("memcache server: %v", err)
+			}
+		}()
+	}
+}
+
+// applyGroups 让正在运行的group和配置保持一致
+// 新增的group会被创建, 删除的group会被关闭, 大小和数据源的改动立即生效
+// 其它选项在创建group时就已经确定, 修改后需要重启才能生效
+func (s *server) applyGroups(cfg *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make(map[string]bool, len(cfg.Groups))
+	for _, gc := range cfg.Groups {
+		names[gc.Name] = true
+		if rg, ok := s.groups[gc.Name]; ok {
+			s.updateGroup(rg, gc)
+			continue
+		}
+
+		getter, closer, err := newGetter(gc.Getter)
+		if err != nil {
+			return fmt.Errorf("group %s: %v", gc.Name, err)
+		}
+		g, err := s.registry.NewGroup(gc.Name, gc.CacheBytes, getter, groupOptions(gc, s.budget)...)
+		if err != nil {
+			if closer != nil {
+				_ = closer.Close()
+			}
+			return err
+		}
+		g.RegisterPeerPicker(s.pool)
+		s.groups[gc.Name] = &runningGroup{Group: g, cfg: gc, closer: closer}
+		log.Printf("group %s is ready", gc.Name)
+	}
+
+	for name, rg := range s.groups {
+		if !names[name] {
+			rg.close()
+			delete(s.groups, name)
+			log.Printf("group %s is closed", name)
+		}
+	}
+	return nil
+}
+
+func (s *server) updateGroup(rg *runningGroup, gc GroupConfig) {
+	if rg.cfg.CacheBytes != gc.CacheBytes {
+		rg.Resize(gc.CacheBytes)
+		rg.cfg.CacheBytes = gc.CacheBytes
+		log.Printf("group %s resized to %d bytes", gc.Name, gc.CacheBytes)
+	}
+	if !reflect.DeepEqual(rg.cfg.Getter, gc.Getter) {
+		if err := rg.setGetter(gc.Getter); err != nil {
+			log.Printf("swap getter of group %s failed, keep the old one: %v", gc.Name, err)
+		} else {
+			log.Printf("getter of group %s swapped to %s", gc.Name, gc.Getter.Type)
+		}
+	}
+	if !reflect.DeepEqual(rg.cfg, gc) {
+		log.Printf("other options of group %s changed, they take effect after a restart", gc.Name)
+	}
+}
+
+// reload 应用新的配置, 监听地址和self的改动需要重启才能生效
+func (s *server) reload(next *Config) {
+	prev := s.cfg
+	if !reflect.DeepEqual(prev.Peers, next.Peers) {
+		s.pool.Set(next.Peers...)
+		log.Printf("peers updated to %v", next.Peers)
+	}
+	if prev.Self != next.Self || prev.Listen != next.Listen ||
+		prev.RESPListen != next.RESPListen || prev.MemcacheListen != next.MemcacheListen {
+		log.Printf("self and listen addresses can not be changed without a restart")
+	}
+	if prev.MemoryMonitor != next.MemoryMonitor || prev.MemoryLimit != next.MemoryLimit {
+		log.Printf("memory monitor settings can not be changed without a restart")
+	}
+	if prev.MemoryBudget != next.MemoryBudget {
+		if s.budget != nil && next.MemoryBudget > 0 {
+			s.budget.SetLimit(next.MemoryBudget)
+			log.Printf("memory budget updated to %d bytes", next.MemoryBudget)
+		} else {
+			log.Printf("memory budget can not be enabled or disabled without a restart")
+		}
+	}
+
+	if err := s.applyGroups(next); err != nil {
+		log.Printf("apply groups failed: %v", err)
+	}
+	s.cfg = next
+}
+
+// shutdown 等待正在处理的请求结束, 然后保存快照并关闭所有group
+func (s *server) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.ShutdownWait))
+	defer cancel()
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		log.Printf("shutdown http server: %v", err)
+	}
+	if s.resp != nil {
+		_ = s.resp.Close()
+	}
+	if s.mc != nil {
+		_ = s.mc.Close()
+	}
+	if s.monitor != nil {
+		s.monitor.Stop()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rg := range s.groups {
+		rg.close()
+	}
+	log.Printf("simplecache-server %s stopped", s.cfg.Self)
+}
+
+func groupOptions(gc GroupConfig, budget *simpleCache.Budget) []simpleCache.Option {
+	var opts []simpleCache.Option
+	if gc.CountOverhead {
+		opts = append(opts, simpleCache.WithEntryOverhead())
+	}
+	if gc.ConcurrentReads {
+		opts = append(opts, simpleCache.WithConcurrentReads())
+	}
+	if gc.Storage == "slab" {
+		opts = append(opts, simpleCache.WithSlabStorage())
+	}
+	if budget != nil {
+		opts = append(opts, simpleCache.WithBudget(budget, gc.BudgetReserve, gc.BudgetWeight))
+	}
+	if gc.TTL > 0 {
+		opts = append(opts, simpleCache.WithTTL(time.Duration(gc.TTL)))
+	}
+	if gc.SoftTTL > 0 {
+		opts = append(opts, simpleCache.WithSoftTTL(time.Duration(gc.SoftTTL)))
+	}
+	if gc.StaleIfError > 0 {
+		opts = append(opts, simpleCache.WithStaleIfError(time.Duration(gc.StaleIfError)))
+	}
+	if gc.Snapshot != "" {
+		opts = append(opts, simpleCache.WithSnapshotFile(gc.Snapshot, time.Duration(gc.SnapshotInterval)))
+	}
+	if gc.DiskDir != "" {
+		opts = append(opts, simpleCache.WithDiskTier(gc.DiskDir, gc.DiskBytes))
+	}
+	if gc.CompressAbove > 0 {
+		opts = append(opts, simpleCache.WithCompression(simpleCache.GzipCompressor{Level: gzip.DefaultCompression}, gc.CompressAbove))
+	}
+	return opts
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReloadGroups(t *testing.T) {
+	static := func(v string) BackendConfig {
+		return BackendConfig{Type: "static", Values: map[string]string{"k": v}}
+	}
+	cfg := &Config{
+		Listen: ":0",
+		Self:   "http://self",
+		Peers:  []string{"http://self"},
+		Groups: []GroupConfig{
+			{Name: "kept", CacheBytes: 1 << 10, Getter: static("v1")},
+			{Name: "removed", Getter: static("v1")},
+		},
+	}
+	s := newServer(cfg)
+	if err := s.applyGroups(cfg); err != nil {
+		t.Fatal(err)
+	}
+	kept := s.registry.GetGroup("kept")
+	removed := s.registry.GetGroup("removed")
+	if v, err := kept.Get("k"); err != nil || v.String() != "v1" {
+		t.Fatalf("get from kept got %v, %v", v, err)
+	}
+
+	next := &Config{
+		Listen: ":0",
+		Self:   "http://self",
+		Peers:  []string{"http://self"},
+		Groups: []GroupConfig{
+			{Name: "kept", CacheBytes: 1, Getter: static("v2")},
+			{Name: "added", Getter: static("v1")},
+		},
+	}
+	s.reload(next)
+
+	if s.registry.GetGroup("kept") != kept {
+		t.Fatal("kept group should not be recreated")
+	}
+	if st := kept.Stats(); st.Items != 0 {
+		t.Fatalf("kept group should be shrunk, still has %d items", st.Items)
+	}
+	if v, err := kept.Get("k"); err != nil || v.String() != "v2" {
+		t.Fatalf("getter of kept group should be swapped, got %v, %v", v, err)
+	}
+	if s.registry.GetGroup("removed") != nil {
+		t.Fatal("removed group should be unregistered")
+	}
+	if _, err := removed.Get("k"); err == nil {
+		t.Fatal("removed group should be closed")
+	}
+	if s.registry.GetGroup("added") == nil {
+		t.Fatal("added group should be created")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"simpleCache"
+	"simpleCache/client"
+	"simpleCache/consistenthash"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// simplecache 运维用的命令行工具, 通过节点的APIHandler接口操作集群
+// 用法: simplecache [-node addr] [-timeout d] <command> [args]
+
+const usage = `usage: simplecache [-node addr] [-timeout d] <command> [args]
+
+commands:
+  get <group> <key>                 read a key from the node
+  set [-ttl d] <group> <key> <value> write a key to the node
+  del <group> <key>                 delete a key from the node
+  stats [group]                     dump per-group stats
+  ring [-peers a,b,c] [key]         show the owner of key and the virtual-node distribution
+  peers                             list cluster membership
+  snapshot <group>                  dump the group to its snapshot file
+`
+
+var (
+	node    string
+	timeout time.Duration
+	httpc   *http.Client
+)
+
+func main() {
+	flag.StringVar(&node, "node", "http://localhost:9999", "API address of the node")
+	flag.DurationVar(&timeout, "timeout", 5*time.Second, "request timeout")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	httpc = &http.Client{Timeout: timeout}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "get":
+		err = get(args[1:])
+	case "set":
+		err = set(args[1:])
+	case "del":
+		err = del(args[1:])
+	case "stats":
+		err = stats(args[1:])
+	case "ring":
+		err = ring(args[1:])
+	case "peers":
+		err = peers(args[1:])
+	case "snapshot":
+		err = snapshot(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// nodeClient 只包含当前节点的client, 所有请求都发给这个节点
+func nodeClient() *client.Client {
+	return client.New([]string{node}, client.WithTimeout(timeout), client.WithRetries(0, 0))
+}
+
+func get(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: get <group> <key>")
+	}
+	value, err := nodeClient().Get(context.Background(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(value, '\n'))
+	return err
+}
+
+func set(args []string) error {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	ttl := fs.Duration("ttl", 0, "time to live, 0 uses the group default")
+	_ = fs.Parse(args)
+	if fs.NArg() != 3 {
+		return errors.New("usage: set [-ttl d] <group> <key> <value>")
+	}
+	return nodeClient().Set(context.Background(), fs.Arg(0), fs.Arg(1), []byte(fs.Arg(2)), *ttl)
+}
+
+func del(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: del <group> <key>")
+	}
+	return nodeClient().Delete(context.Background(), args[0], args[1])
+}
+
+func stats(args []string) error {
+	var list []simpleCache.Stats
+	if len(args) == 1 {
+		var s simpleCache.Stats
+		if err := getJSON("/groups/"+url.PathEscape(args[0]), &s); err != nil {
+			return err
+		}
+		list = append(list, s)
+	} else if err := getJSON("/groups", &list); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tGETS\tHITS\tHIT%\tLOADS\tPEER_LOADS\tPEER_ERRORS\tLOCAL_ERRORS\tSTALE\tITEMS\tBYTES")
+	for _, s := range list {
+		hitRate := 0.0
+		if s.Gets > 0 {
+			hitRate = float64(s.Hits) * 100 / float64(s.Gets)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			s.Name, s.Gets, s.Hits, hitRate, s.Loads, s.PeerLoads, s.PeerErrors,
+			s.LocalErrors, s.StaleServed, s.Items, s.Bytes)
+	}
+	return w.Flush()
+}
+
+func ring(args []string) error {
+	fs := flag.NewFlagSet("ring", flag.ExitOnError)
+	peerFlag := fs.String("peers", "", "comma separated peers, fetched from the node when empty")
+	replicas := fs.Int("replicas", simpleCache.DefaultReplicas, "virtual nodes per peer")
+	_ = fs.Parse(args)
+
+	var list []string
+	if *peerFlag != "" {
+		list = strings.Split(*peerFlag, ",")
+	} else {
+		var info simpleCache.PeersInfo
+		if err := getJSON("/peers", &info); err != nil {
+			return err
+		}
+		list = info.Peers
+	}
+
+	m := consistenthash.New(*replicas, nil)
+	m.Add(list...)
+	if fs.NArg() > 0 {
+		fmt.Printf("key %q is owned by %s\n\n", fs.Arg(0), m.Get(fs.Arg(0)))
+	}
+
+	dist := m.Distribution()
+	sort.Strings(list)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER\tVNODES\tSHARE%")
+	for _, p := range list {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\n", p, m.Replicas(), dist[p]*100)
+	}
+	return w.Flush()
+}
+
+func peers(args []string) error {
+	var info simpleCache.PeersInfo
+	if err := getJSON("/peers", &info); err != nil {
+		return err
+	}
+	sort.Strings(info.Peers)
+	for _, p := range info.Peers {
+		mark := ""
+		if p == info.Self {
+			mark = " (self)"
+		}
+		fmt.Println(p + mark)
+	}
+	return nil
+}
+
+func snapshot(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: snapshot <group>")
+	}
+	resp, err := httpc.Post(strings.TrimSuffix(node, "/")+"/groups/"+url.PathEscape(args[0])+"/snapshot", "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkStatus(resp); err != nil {
+		return err
+	}
+	fmt.Println("snapshot saved")
+	return nil
+}
+
+func getJSON(path string, v any) error {
+	resp, err := httpc.Get(strings.TrimSuffix(node, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,61 @@
+package simpleCache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 负责V和缓存中实际保存的[]byte之间的相互转换
+type Codec[V any] interface {
+	Encode(v V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// JSONCodec 使用encoding/json编码
+type JSONCodec[V any] struct{}
+
+func (JSONCodec[V]) Encode(v V) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[V]) Decode(data []byte) (V, error) {
+	var v V
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用encoding/gob编码
+// 每个值单独编码, 所以每份数据中都带有类型信息
+type GobCodec[V any] struct{}
+
+func (GobCodec[V]) Encode(v V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[V]) Decode(data []byte) (V, error) {
+	var v V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec 使用protobuf编码, V需要是生成的消息指针类型, 如*pb.Request
+type ProtoCodec[V proto.Message] struct{}
+
+func (ProtoCodec[V]) Encode(v V) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (ProtoCodec[V]) Decode(data []byte) (V, error) {
+	// 生成的消息类型即使是nil指针也可以拿到类型信息, 以此创建新的消息
+	var zero V
+	v := zero.ProtoReflect().Type().New().Interface().(V)
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
@@ -0,0 +1,109 @@
+package simpleCache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Compressor 压缩算法, 通过RegisterCompressor注册后才能在节点之间使用
+// 可以按需实现snappy、zstd等算法
+type Compressor interface {
+	// Name 算法的名字, 节点之间协商编码时使用, 需要全局唯一
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// StreamDecompressor Compressor可以选择实现
+// 以流的方式解压, 发送较大的数据时不需要先在内存中整体解压
+type StreamDecompressor interface {
+	DecompressReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = make(map[string]Compressor)
+)
+
+func init() {
+	RegisterCompressor(GzipCompressor{Level: gzip.DefaultCompression})
+}
+
+// RegisterCompressor 注册压缩算法, 同名的算法会被覆盖
+// 节点只会接收自己注册过的编码的数据
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+func getCompressor(name string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// compressorNames 本节点可以解压的所有编码
+func compressorNames() []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	names := make([]string, 0, len(compressors))
+	for name := range compressors {
+		names = append(names, name)
+	}
+	return names
+}
+
+// GzipCompressor 使用compress/gzip压缩
+type GzipCompressor struct {
+	Level int
+}
+
+func (GzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (GzipCompressor) DecompressReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// compress 数据不小于阈值时进行压缩, 压缩后没有变小则保留原数据
+func (g *Group) compress(view ByteView) ByteView {
+	if g.compressor == nil || view.Len() < g.compressThreshold {
+		return view
+	}
+	data, err := g.compressor.Compress(view.b)
+	if err != nil || len(data) >= view.Len() {
+		return view
+	}
+	view.b = data
+	view.encoding = g.compressor.Name()
+	return view
+}
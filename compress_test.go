@@ -0,0 +1,73 @@
+package simpleCache
+
+import (
+	"net/http/httptest"
+	"simpleCache/pb"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	reg := NewRegistry()
+	html := strings.Repeat("<div>simpleCache</div>", 1000)
+	sim := newTestGroup(t, reg, "compression", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "small" {
+				return []byte("tiny"), nil
+			}
+			return []byte(html), nil
+		}), WithCompression(GzipCompressor{Level: 6}, 1024))
+
+	view, err := sim.Get("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Encoding() != "gzip" || view.Len() >= len(html) {
+		t.Fatalf("large value should be compressed, encoding %q len %d", view.Encoding(), view.Len())
+	}
+	if view.String() != html || string(view.ByteSlice()) != html {
+		t.Fatal("compressed value is not decompressed on access")
+	}
+	if view.Version() != versionOf([]byte(html)) {
+		t.Fatal("version should be computed from the uncompressed value")
+	}
+
+	if small, _ := sim.Get("small"); small.Encoding() != "" {
+		t.Fatal("value below the threshold should not be compressed")
+	}
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	reg := NewRegistry()
+	html := strings.Repeat("<p>hello</p>", 1000)
+	sim := newTestGroup(t, reg, "compression-peer", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(html), nil
+		}), WithCompression(GzipCompressor{Level: 6}, 1024))
+	server := httptest.NewServer(reg.NewHttpPool("owner"))
+	defer server.Close()
+	getter := NewHttpGetter(server.URL + defaultBasePath)
+
+	compressed := &pb.Response{}
+	req := &pb.Request{Group: "compression-peer", Key: "k", AcceptEncodings: []string{"gzip"}}
+	if err := getter.GetDataFromPeer(req, compressed); err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Encoding != "gzip" || len(compressed.Value) >= len(html) {
+		t.Fatal("peer accepting gzip should receive the compressed value")
+	}
+
+	plain := &pb.Response{}
+	req.AcceptEncodings = nil
+	if err := getter.GetDataFromPeer(req, plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Encoding != "" || string(plain.Value) != html {
+		t.Fatal("peer without gzip should receive the uncompressed value")
+	}
+
+	view, err := sim.getFromPeer(getter, "k")
+	if err != nil || view.Encoding() != "gzip" || view.String() != html {
+		t.Fatalf("getFromPeer should keep the value compressed: %v", err)
+	}
+}
@@ -46,6 +46,10 @@ func (m *Map) Add(keys ...string) {
 
 // Get 通过想要得到的缓存内容key,得到未该key负责的peer
 func (m *Map) Get(key string) string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+
 	hashKey := m.hashFunc([]byte(key))
 	idx := sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= int(hashKey)
@@ -53,3 +57,27 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
+
+// Distribution 每个peer负责的哈希空间占整个哈希环的比例
+// 可以用来观察虚拟节点数是否足够让负载均衡
+func (m *Map) Distribution() map[string]float64 {
+	res := make(map[string]float64)
+	if len(m.keys) == 0 {
+		return res
+	}
+
+	// 每个虚拟节点负责从上一个虚拟节点(不含)到自己(含)的区间, 第一个虚拟节点还负责环尾部的区间
+	const ringSize = float64(1 << 32)
+	// 用int64计算, 32位平台上int放不下1<<32
+	prev := int64(m.keys[len(m.keys)-1]) - 1<<32
+	for _, k := range m.keys {
+		res[m.hashMap[k]] += float64(int64(k)-prev) / ringSize
+		prev = int64(k)
+	}
+	return res
+}
+
+// Replicas 每个peer的虚拟节点数
+func (m *Map) Replicas() int {
+	return m.replicas
+}
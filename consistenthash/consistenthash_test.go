@@ -40,3 +40,24 @@ func TestHashing(t *testing.T) {
 		}
 	}
 }
+
+func TestDistribution(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	hash.Add("6", "4", "2")
+
+	// 4负责(2, 4]、(12, 14]和(22, 24]
+	dist := hash.Distribution()
+	total := 0.0
+	for _, share := range dist {
+		total += share
+	}
+	if total < 0.999999 || total > 1.000001 {
+		t.Fatalf("shares should sum to 1, got %f", total)
+	}
+	if dist["4"] != 6.0/(1<<32) {
+		t.Fatalf("share of 4 should be 6/2^32, got %g", dist["4"])
+	}
+}
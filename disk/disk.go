@@ -0,0 +1,362 @@
+package disk
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/* 基于追加写文件的磁盘缓存
+ * 作为内存LRU之下的第二级缓存, 所有写入都追加到同一个segment文件
+ * 内存中只保存key到文件偏移的索引
+ * 被覆盖、删除或过期的记录在compaction时才会真正从文件中清理掉
+ */
+
+// 每条记录的格式:
+// crc32(4) | flag(1) | keyLen(4) | valLen(4) | expire(8) | key | value
+// crc覆盖crc之后的全部内容, expire为UnixNano, 0表示永不过期
+const (
+	headerSize = 4 + 1 + 4 + 4 + 8
+
+	flagPut    = 0
+	flagDelete = 1
+
+	segmentName = "data.seg"
+
+	// 垃圾数据超过这个大小且超过有效数据时才进行compaction,避免过于频繁地重写文件
+	compactMinGarbage = 1 << 20
+)
+
+var ErrTooLarge = errors.New("entry is larger than the disk limit")
+
+// Store 磁盘缓存, 并发安全
+type Store struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64 // 有效数据的大小上限, 0时不进行限制
+
+	f    *os.File
+	size int64 // 文件大小
+	live int64 // 有效记录的大小
+
+	index map[string]*list.Element
+	ll    *list.List // 按写入顺序排列, 超出大小限制时从队头淘汰
+}
+
+// 索引中保存的记录位置
+type item struct {
+	key    string
+	offset int64
+	size   int64 // 整条记录的大小
+	expire int64
+}
+
+// Open 打开dir下的segment文件并重建索引, 文件不存在时会新建
+// 文件尾部不完整或校验失败的记录会被截断
+func Open(dir string, maxBytes int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, segmentName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:      dir,
+		maxBytes: maxBytes,
+		f:        f,
+		index:    make(map[string]*list.Element),
+		ll:       list.New(),
+	}
+	if err = s.replay(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay 顺序读取文件中的所有记录, 重建内存索引
+func (s *Store) replay() error {
+	stat, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := stat.Size()
+
+	now := time.Now().UnixNano()
+	header := make([]byte, headerSize)
+	var offset int64
+	evicted := false
+	for {
+		if _, err := s.f.ReadAt(header, offset); err != nil {
+			break
+		}
+		flag, keyLen, valLen, expire := decodeHeader(header)
+		size := int64(headerSize) + int64(keyLen) + int64(valLen)
+		if offset+size > fileSize {
+			break
+		}
+		body := make([]byte, int64(keyLen)+int64(valLen))
+		if _, err := s.f.ReadAt(body, offset+headerSize); err != nil {
+			break
+		}
+		crc := crc32.NewIEEE()
+		_, _ = crc.Write(header[4:])
+		_, _ = crc.Write(body)
+		if crc.Sum32() != binary.BigEndian.Uint32(header) {
+			break
+		}
+
+		key := string(body[:keyLen])
+		s.drop(key)
+		if flag == flagPut && (expire == 0 || expire > now) {
+			s.insert(&item{key: key, offset: offset, size: size, expire: expire})
+		}
+		// 大小上限可能在重启时被调小, 和Put一样淘汰最早写入的数据
+		for s.maxBytes != 0 && s.live > s.maxBytes {
+			s.drop(s.ll.Front().Value.(*item).key)
+			evicted = true
+		}
+		offset += size
+	}
+
+	// 截掉尾部不完整的记录, 后续从这里继续追加
+	if err := s.f.Truncate(offset); err != nil {
+		return err
+	}
+	s.size = offset
+	// 重放时淘汰的数据没有删除记录, 重写文件避免它们在下次重启时复活
+	if evicted {
+		return s.compact()
+	}
+	return nil
+}
+
+// Get 读取key对应的数据, 返回数据和过期时间
+func (s *Store) Get(key string) ([]byte, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ele, ok := s.index[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	it := ele.Value.(*item)
+	if it.expire != 0 && it.expire <= time.Now().UnixNano() {
+		s.drop(key)
+		return nil, time.Time{}, false
+	}
+
+	value := make([]byte, it.size-headerSize-int64(len(key)))
+	if _, err := s.f.ReadAt(value, it.offset+headerSize+int64(len(key))); err != nil {
+		s.drop(key)
+		return nil, time.Time{}, false
+	}
+	return value, unixTime(it.expire), true
+}
+
+// Put 写入数据, expire为零值表示永不过期
+// 超过大小限制时会淘汰最早写入的数据
+func (s *Store) Put(key string, value []byte, expire time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(headerSize) + int64(len(key)) + int64(len(value))
+	if s.maxBytes != 0 && size > s.maxBytes {
+		return ErrTooLarge
+	}
+
+	var exp int64
+	if !expire.IsZero() {
+		exp = expire.UnixNano()
+	}
+	offset, err := s.append(flagPut, key, value, exp)
+	if err != nil {
+		return err
+	}
+
+	s.drop(key)
+	s.insert(&item{key: key, offset: offset, size: size, expire: exp})
+	// 淘汰的数据同样写入删除记录, 重启后不会复活
+	for s.maxBytes != 0 && s.live > s.maxBytes {
+		oldest := s.ll.Front().Value.(*item).key
+		if _, err = s.append(flagDelete, oldest, nil, 0); err != nil {
+			return err
+		}
+		s.drop(oldest)
+	}
+	return s.maybeCompact()
+}
+
+// Delete 删除数据, 会写入一条删除记录以保证重启后数据不会复活
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[key]; !ok {
+		return nil
+	}
+	if _, err := s.append(flagDelete, key, nil, 0); err != nil {
+		return err
+	}
+	s.drop(key)
+	return s.maybeCompact()
+}
+
+// Purge 删除所有数据并清空segment文件, 重启后也不会恢复
+func (s *Store) Purge() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	s.size, s.live = 0, 0
+	s.index = make(map[string]*list.Element)
+	s.ll.Init()
+	return nil
+}
+
+// Len 有效的数据条数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// Bytes 有效数据占用的字节数
+func (s *Store) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live
+}
+
+// Close 关闭segment文件
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// Compact 立即重写segment文件, 只保留有效数据
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+func (s *Store) maybeCompact() error {
+	garbage := s.size - s.live
+	if garbage > s.live && garbage > compactMinGarbage {
+		return s.compact()
+	}
+	if s.maxBytes != 0 && s.size > 2*s.maxBytes {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact 把有效记录按原顺序复制到新文件, 再替换掉旧文件
+func (s *Store) compact() error {
+	path := filepath.Join(s.dir, segmentName)
+	tmpPath := path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	now := time.Now().UnixNano()
+	var offset int64
+	offsets := make(map[*item]int64, s.ll.Len())
+	for ele := s.ll.Front(); ele != nil; {
+		next := ele.Next()
+		it := ele.Value.(*item)
+		if it.expire != 0 && it.expire <= now {
+			s.drop(it.key)
+			ele = next
+			continue
+		}
+		if _, err = io.Copy(tmp, io.NewSectionReader(s.f, it.offset, it.size)); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		offsets[it] = offset
+		offset += it.size
+		ele = next
+	}
+
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	_ = s.f.Close()
+	s.f = tmp
+	s.size = offset
+	for it, off := range offsets {
+		it.offset = off
+	}
+	return nil
+}
+
+// append 在文件末尾追加一条记录, 返回记录的偏移
+func (s *Store) append(flag byte, key string, value []byte, expire int64) (int64, error) {
+	buf := make([]byte, headerSize+len(key)+len(value))
+	buf[4] = flag
+	binary.BigEndian.PutUint32(buf[5:], uint32(len(key)))
+	binary.BigEndian.PutUint32(buf[9:], uint32(len(value)))
+	binary.BigEndian.PutUint64(buf[13:], uint64(expire))
+	copy(buf[headerSize:], key)
+	copy(buf[headerSize+len(key):], value)
+	binary.BigEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+
+	offset := s.size
+	if _, err := s.f.WriteAt(buf, offset); err != nil {
+		return 0, err
+	}
+	s.size += int64(len(buf))
+	return offset, nil
+}
+
+func (s *Store) insert(it *item) {
+	s.index[it.key] = s.ll.PushBack(it)
+	s.live += it.size
+}
+
+// drop 只从索引中移除, 文件中的记录变成垃圾数据
+func (s *Store) drop(key string) {
+	ele, ok := s.index[key]
+	if !ok {
+		return
+	}
+	s.ll.Remove(ele)
+	delete(s.index, key)
+	s.live -= ele.Value.(*item).size
+}
+
+func decodeHeader(header []byte) (flag byte, keyLen, valLen uint32, expire int64) {
+	flag = header[4]
+	keyLen = binary.BigEndian.Uint32(header[5:])
+	valLen = binary.BigEndian.Uint32(header[9:])
+	expire = int64(binary.BigEndian.Uint64(header[13:]))
+	return
+}
+
+func unixTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
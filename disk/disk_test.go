@@ -0,0 +1,144 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err = s.Put("key1", []byte("1234"), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, ok := s.Get("key1"); !ok || string(v) != "1234" {
+		t.Fatalf("disk hit key1=1234 failed")
+	}
+	if _, _, ok := s.Get("key2"); ok {
+		t.Fatalf("disk miss key2 failed")
+	}
+
+	_ = s.Put("key3", []byte("v3"), time.Now().Add(-time.Second))
+	if _, _, ok := s.Get("key3"); ok {
+		t.Fatalf("expired key3 should miss")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Put("k1", []byte("v1"), time.Time{})
+	_ = s.Put("k2", []byte("v2"), time.Time{})
+	_ = s.Put("k1", []byte("v1-new"), time.Time{})
+	_ = s.Delete("k2")
+	_ = s.Close()
+
+	// 模拟写到一半时进程退出
+	f, _ := os.OpenFile(filepath.Join(dir, segmentName), os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.Write([]byte{1, 2, 3})
+	_ = f.Close()
+
+	s, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, _, ok := s.Get("k1"); !ok || string(v) != "v1-new" {
+		t.Fatalf("k1 should be v1-new after reopen")
+	}
+	if _, _, ok := s.Get("k2"); ok {
+		t.Fatalf("deleted k2 should not come back after reopen")
+	}
+
+	// 截断后可以继续正常写入
+	_ = s.Put("k3", []byte("v3"), time.Time{})
+	if v, _, ok := s.Get("k3"); !ok || string(v) != "v3" {
+		t.Fatalf("put after truncated tail failed")
+	}
+}
+
+func TestLimitAndCompact(t *testing.T) {
+	record := int64(headerSize + 2 + 4)
+	s, err := Open(t.TempDir(), 3*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		_ = s.Put(k, []byte("vvvv"), time.Time{})
+	}
+	if _, _, ok := s.Get("k1"); ok || s.Len() != 3 {
+		t.Fatalf("oldest k1 should be dropped by the disk limit")
+	}
+
+	if err = s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if s.size != s.live {
+		t.Fatalf("file size %d should equal live bytes %d after compaction", s.size, s.live)
+	}
+	for _, k := range []string{"k2", "k3", "k4"} {
+		if v, _, ok := s.Get(k); !ok || string(v) != "vvvv" {
+			t.Fatalf("%s is lost after compaction", k)
+		}
+	}
+
+	if err = s.Put("big", make([]byte, 4*record), time.Time{}); err != ErrTooLarge {
+		t.Fatalf("entry larger than the limit should be rejected")
+	}
+}
+
+func TestReopenAfterLimit(t *testing.T) {
+	dir := t.TempDir()
+	record := int64(headerSize + 2 + 4)
+	s, err := Open(dir, 2*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"k1", "k2", "k3"} {
+		_ = s.Put(k, []byte("vvvv"), time.Time{})
+	}
+	_ = s.Close()
+
+	// 上限调大后, 之前因为容量被淘汰的k1也不能复活
+	s, err = Open(dir, 3*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := s.Get("k1"); ok || s.Len() != 2 {
+		t.Fatalf("k1 dropped by the disk limit should not come back after reopen")
+	}
+	_ = s.Close()
+
+	// 上限调小后, 重放时同样淘汰最早写入的数据
+	s, err = Open(dir, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := s.Get("k2"); ok || s.Len() != 1 || s.Bytes() > record {
+		t.Fatalf("replay should enforce the smaller disk limit")
+	}
+	if v, _, ok := s.Get("k3"); !ok || string(v) != "vvvv" {
+		t.Fatalf("newest k3 should survive the smaller disk limit")
+	}
+	_ = s.Close()
+
+	s, err = Open(dir, 3*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, _, ok := s.Get("k2"); ok || s.Len() != 1 {
+		t.Fatalf("k2 dropped during replay should not come back")
+	}
+}
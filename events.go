@@ -0,0 +1,134 @@
+package simpleCache
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// defaultEventQueueSize 事件队列的默认长度
+const defaultEventQueueSize = 1024
+
+// EventType group事件的类型
+type EventType int
+
+const (
+	EventEvict     EventType = iota + 1 // 数据离开内存缓存, 原因见Event.Reason
+	EventHit                            // Get命中本地缓存
+	EventMiss                           // Get未命中本地缓存
+	EventLoadStart                      // 开始加载数据, 被singleflight合并的请求只有一次
+	EventLoadDone                       // 加载结束, 成功时Value是加载到的数据
+	EventPeerFetch                      // 向peer请求数据结束, 失败后还会从本地数据源加载
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventEvict:
+		return "evict"
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventLoadStart:
+		return "load-start"
+	case EventLoadDone:
+		return "load-done"
+	case EventPeerFetch:
+		return "peer-fetch"
+	}
+	return "unknown"
+}
+
+// EvictReason 数据离开内存缓存的原因
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota + 1 // 容量或共享预算不足被淘汰, 配置了磁盘缓存时可能被写入磁盘
+	EvictExpired                         // 已经过期
+	EvictRemoved                         // 被Remove或Purge主动删除
+	EvictReplaced                        // 被同一个key的新数据覆盖
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// Event 传给Listener的事件, 不同类型的事件只填写相关的字段
+type Event struct {
+	Type   EventType
+	Group  string
+	Key    string
+	Time   time.Time
+	Reason EvictReason // 只用于EventEvict
+
+	// EventEvict中是离开缓存的旧数据, EventHit、EventLoadDone和EventPeerFetch中是得到的数据
+	Value ByteView
+
+	Err      error         // EventLoadDone和EventPeerFetch的错误
+	Duration time.Duration // EventLoadDone和EventPeerFetch的耗时
+}
+
+// Listener 接收group事件的回调, 在group专属的goroutine中按事件发生的顺序调用
+type Listener func(e Event)
+
+// emit 把事件放进队列, 队列满时丢弃事件, 不会阻塞调用方
+func (g *Group) emit(e Event) {
+	if g.events == nil {
+		return
+	}
+	e.Group = g.name
+	e.Time = time.Now()
+	select {
+	case g.events <- e:
+	default:
+		atomic.AddInt64(&g.stats.eventsDropped, 1)
+	}
+}
+
+// notifyEvict 作为cache的淘汰通知, 调用时持有cache.mu, 只能做入队操作
+func (g *Group) notifyEvict(key string, value ByteView, reason EvictReason) {
+	g.emit(Event{Type: EventEvict, Key: key, Value: value, Reason: reason})
+}
+
+// dispatchEvents 把队列中的事件依次交给所有Listener, Close之后投递完已经排队的事件再退出
+func (g *Group) dispatchEvents() {
+	for {
+		select {
+		case e := <-g.events:
+			g.deliver(e)
+		case <-g.done:
+			for {
+				select {
+				case e := <-g.events:
+					g.deliver(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (g *Group) deliver(e Event) {
+	for _, l := range g.listeners {
+		func() {
+			// 一个Listener出错不影响其它Listener和后续事件
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("listener of group %s panicked on %s event: %v", g.name, e.Type, r)
+				}
+			}()
+			l(e)
+		}()
+	}
+}
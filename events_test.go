@@ -0,0 +1,112 @@
+package simpleCache
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// eventRecorder 把收到的事件转换成便于比较的字符串
+type eventRecorder chan string
+
+func (r eventRecorder) listen(e Event) {
+	s := e.Type.String() + " " + e.Key
+	if e.Type == EventEvict {
+		s += " " + e.Reason.String()
+	}
+	if e.Err != nil {
+		s += " error"
+	}
+	r <- s
+}
+
+func (r eventRecorder) expect(t *testing.T, events ...string) {
+	t.Helper()
+	var got []string
+	for range events {
+		select {
+		case e := <-r:
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatalf("got events %v, want %v", got, events)
+		}
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Fatalf("got events %v, want %v", got, events)
+	}
+}
+
+func TestListener(t *testing.T) {
+	reg := NewRegistry()
+	events := make(eventRecorder, 100)
+	g := newTestGroup(t, reg, "events", int64(len("k1v1k2v2")), GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "bad" {
+				return nil, errors.New("bad key")
+			}
+			return []byte("v" + key[1:]), nil
+		}), WithListener(events.listen))
+	defer g.Close()
+
+	_, _ = g.Get("k1")
+	_, _ = g.Get("k1")
+	events.expect(t, "miss k1", "load-start k1", "load-done k1", "hit k1")
+	_, _ = g.Get("bad")
+	events.expect(t, "miss bad", "load-start bad", "load-done bad error")
+
+	_ = g.Set("k2", []byte("v2"))
+	_ = g.Set("k2", []byte("v2"))
+	events.expect(t, "evict k2 replaced")
+	_ = g.Set("k3", []byte("v3"))
+	events.expect(t, "evict k1 capacity")
+	g.Remove("k2")
+	events.expect(t, "evict k2 removed")
+	_ = g.Set("k4", []byte("v4"), SetTTL(time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = g.Get("k4")
+	events.expect(t, "evict k4 expired", "miss k4", "load-start k4", "load-done k4")
+	g.Purge()
+	events.expect(t, "evict k3 removed", "evict k4 removed")
+}
+
+func TestSlowListener(t *testing.T) {
+	reg := NewRegistry()
+	release := make(chan struct{})
+	var delivered int64
+	g := newTestGroup(t, reg, "slow-listener", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithEventQueueSize(4), WithListener(func(e Event) {
+		<-release
+		atomic.AddInt64(&delivered, 1)
+	}), WithListener(func(e Event) {
+		panic("broken listener")
+	}))
+
+	// 队列满了之后Get也不会被阻塞
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			_, _ = g.Get("k")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("slow listener should not block Get")
+	}
+	if g.Stats().EventsDropped == 0 {
+		t.Fatal("events should be dropped when the queue is full")
+	}
+
+	// Close之后投递完已经排队的事件, panic的Listener不影响其它Listener
+	close(release)
+	_ = g.Close()
+	waitFor(t, func() bool {
+		n := atomic.LoadInt64(&delivered)
+		return n > 0 && n+g.Stats().EventsDropped == 102
+	})
+}
@@ -21,7 +21,7 @@ func createGroup() *simpleCache.Group {
 			if v, ok := dbMulti[key]; ok {
 				return []byte(v), nil
 			}
-			return nil, fmt.Errorf("%s not exist", key)
+			return nil, fmt.Errorf("%s not exist: %w", key, simpleCache.ErrNotFound)
 		}))
 }
 
@@ -33,18 +33,8 @@ func startCacheServer(addr string, addrs []string, sim *simpleCache.Group) {
 	log.Fatal(http.ListenAndServe(addr[7:], peers))
 }
 
-func startAPIServer(apiAddr string, sim *simpleCache.Group) {
-	http.Handle("/api", http.HandlerFunc(
-		func(w http.ResponseWriter, r *http.Request) {
-			key := r.URL.Query().Get("key")
-			view, err := sim.Get(key)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "application/octet-stream")
-			_, _ = w.Write(view.ByteSlice())
-		}))
+func startAPIServer(apiAddr string) {
+	http.Handle("/", simpleCache.NewAPIHandler())
 	log.Println("fontend server is running at", apiAddr)
 	log.Fatal(http.ListenAndServe(apiAddr[7:], nil))
 }
@@ -70,7 +60,7 @@ func main() {
 
 	sim := createGroup()
 	if api {
-		go startAPIServer(apiAddr, sim)
+		go startAPIServer(apiAddr)
 	}
 	startCacheServer(addrMap[port], addrs, sim)
 }
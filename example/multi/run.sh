@@ -8,9 +8,9 @@ go build -o server
 
 sleep 2
 echo ">>> start test"
-curl "http://localhost:9999/api?key=Tom" &
-curl "http://localhost:9999/api?key=Tom" &
-curl "http://localhost:9999/api?key=Tom" &
+curl "http://localhost:9999/groups/scores/keys/Tom" &
+curl "http://localhost:9999/groups/scores/keys/Tom" &
+curl "http://localhost:9999/groups/scores/keys/Tom" &
 # 630
 
 wait
\ No newline at end of file
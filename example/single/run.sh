@@ -6,8 +6,8 @@ go build -o server
 
 sleep 2
 echo ">>> start test"
-curl "http://localhost:9999/api?key=Tom" #630
-curl "http://localhost:9999/api?key=kkk" #not exist
+curl "http://localhost:9999/groups/scores/keys/Tom" #630
+curl "http://localhost:9999/groups/scores/keys/kkk" #not exist
 
 # old test for peer
 #curl http://localhost:9999/_simplecache/scores/Tom
@@ -20,7 +20,7 @@ func main() {
 			if v, ok := dbSingle[key]; ok {
 				return []byte(v), nil
 			}
-			return nil, fmt.Errorf("%s not exist", key)
+			return nil, fmt.Errorf("%s not exist: %w", key, simpleCache.ErrNotFound)
 		}))
 
 	addr := "http://localhost:8001"
@@ -31,17 +31,7 @@ func main() {
 
 	// api server
 	go func() {
-		http.Handle("/api", http.HandlerFunc(
-			func(w http.ResponseWriter, r *http.Request) {
-				key := r.URL.Query().Get("key")
-				view, err := sim.Get(key)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				w.Header().Set("Content-Type", "application/octet-stream")
-				_, _ = w.Write(view.ByteSlice())
-			}))
+		http.Handle("/", simpleCache.NewAPIHandler())
 		log.Println("fontend server is running at", apiAddr)
 		log.Fatal(http.ListenAndServe(apiAddr[7:], nil))
 	}()
@@ -0,0 +1,127 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"simpleCache"
+	"strings"
+	"time"
+)
+
+/* 可以直接作为simpleCache.Getter使用的数据源
+ * HTTP: 从源站 {origin}/{key} 获取数据
+ * SQL: 用key作为参数执行一条查询, 取第一行第一列作为数据
+ * 数据不存在时返回的错误都包装了simpleCache.ErrNotFound
+ */
+
+const (
+	defaultTimeout     = 5 * time.Second
+	defaultMaxBodySize = 64 << 20
+)
+
+// HTTP 从HTTP源站加载数据, 并发安全
+// 200返回响应体, 404和410视为数据不存在, 其它状态码都是错误
+type HTTP struct {
+	origin  string
+	client  *http.Client
+	header  http.Header
+	maxSize int64
+
+	timeout    time.Duration
+	timeoutSet bool // 调用过WithTimeout, 创建时把timeout设置到client上
+}
+
+// HTTPOption 创建HTTP时的额外配置
+type HTTPOption func(h *HTTP)
+
+// WithTimeout 单次请求的超时时间, 默认5秒
+func WithTimeout(timeout time.Duration) HTTPOption {
+	return func(h *HTTP) {
+		h.timeout = timeout
+		h.timeoutSet = true
+	}
+}
+
+// WithHTTPClient 使用自定义的http.Client
+// c由调用方持有, 再加上WithTimeout时使用c的副本, c本身保持不变
+func WithHTTPClient(c *http.Client) HTTPOption {
+	return func(h *HTTP) {
+		h.client = c
+	}
+}
+
+// WithHeader 每个请求都带上的header, 比如源站需要的认证信息
+func WithHeader(key, value string) HTTPOption {
+	return func(h *HTTP) {
+		h.header.Add(key, value)
+	}
+}
+
+// WithMaxBodySize 响应体的大小上限, 超过时返回错误, 默认64MB
+func WithMaxBodySize(n int64) HTTPOption {
+	return func(h *HTTP) {
+		h.maxSize = n
+	}
+}
+
+// NewHTTP origin是源站的地址前缀, 如http://origin.internal/scores
+func NewHTTP(origin string, opts ...HTTPOption) *HTTP {
+	h := &HTTP{
+		origin:  strings.TrimRight(origin, "/"),
+		client:  &http.Client{Timeout: defaultTimeout},
+		header:  make(http.Header),
+		maxSize: defaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.timeoutSet {
+		c := *h.client
+		c.Timeout = h.timeout
+		h.client = &c
+	}
+	return h
+}
+
+var _ simpleCache.Getter = (*HTTP)(nil)
+
+func (h *HTTP) Get(key string) ([]byte, error) {
+	u := h.origin + "/" + url.PathEscape(key)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h.header {
+		req.Header[k] = v
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, fmt.Errorf("%s: %w", key, simpleCache.ErrNotFound)
+	default:
+		return nil, fmt.Errorf("get %s from origin failed with status %d", u, resp.StatusCode)
+	}
+
+	// 多读一个字节用来判断是否超过上限
+	var buf bytes.Buffer
+	if resp.ContentLength > 0 && resp.ContentLength <= h.maxSize {
+		buf.Grow(int(resp.ContentLength) + bytes.MinRead)
+	}
+	if _, err = buf.ReadFrom(io.LimitReader(resp.Body, h.maxSize+1)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) > h.maxSize {
+		return nil, fmt.Errorf("response of %s exceeds %d bytes", u, h.maxSize)
+	}
+	return buf.Bytes(), nil
+}
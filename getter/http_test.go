@@ -0,0 +1,81 @@
+package getter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"simpleCache"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/scores/Tom":
+			_, _ = w.Write([]byte("630"))
+		case "/scores/a/b":
+			_, _ = w.Write([]byte("escaped"))
+		case "/scores/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/scores/broken":
+			http.Error(w, "boom", http.StatusBadGateway)
+		case "/scores/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		case "/scores/slow":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte("slow"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	h := NewHTTP(origin.URL+"/scores/", WithHeader("Authorization", "Bearer token"),
+		WithTimeout(100*time.Millisecond), WithMaxBodySize(64))
+
+	for key, want := range map[string]string{"Tom": "630", "a/b": "escaped"} {
+		v, err := h.Get(key)
+		if err != nil || string(v) != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, v, err, want)
+		}
+	}
+	for _, key := range []string{"unknown", "gone"} {
+		if _, err := h.Get(key); !errors.Is(err, simpleCache.ErrNotFound) {
+			t.Fatalf("Get(%s) should return ErrNotFound, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"broken", "large", "slow"} {
+		_, err := h.Get(key)
+		if err == nil || errors.Is(err, simpleCache.ErrNotFound) {
+			t.Fatalf("Get(%s) should fail, got %v", key, err)
+		}
+	}
+
+	if _, err := NewHTTP(origin.URL + "/scores").Get("Tom"); err == nil {
+		t.Fatal("request without header should fail")
+	}
+}
+
+func TestHTTPWithClientAndTimeout(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+	if h := NewHTTP("http://origin.internal", WithHTTPClient(shared)); h.client != shared {
+		t.Fatal("custom client should be used directly")
+	}
+
+	// 两个选项的先后顺序不影响结果, 共享的client不能被改掉
+	for _, opts := range [][]HTTPOption{
+		{WithHTTPClient(shared), WithTimeout(0)},
+		{WithTimeout(0), WithHTTPClient(shared)},
+	} {
+		h := NewHTTP("http://origin.internal", opts...)
+		if h.client.Timeout != 0 || shared.Timeout != time.Minute {
+			t.Fatalf("timeout is %v, shared client timeout is %v", h.client.Timeout, shared.Timeout)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package getter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"simpleCache"
+	"time"
+)
+
+// SQL 用database/sql执行参数化查询加载数据, 并发安全
+// 查询只能有一个参数, 即key, 占位符按驱动的要求书写, 如
+// SELECT value FROM scores WHERE name = ?
+// 结果取第一行第一列, 没有结果或者值为NULL时视为数据不存在
+type SQL struct {
+	db      *sql.DB
+	query   string
+	timeout time.Duration
+}
+
+// SQLOption 创建SQL时的额外配置
+type SQLOption func(s *SQL)
+
+// WithQueryTimeout 单次查询的超时时间, 默认5秒, 0表示不限制
+func WithQueryTimeout(timeout time.Duration) SQLOption {
+	return func(s *SQL) {
+		s.timeout = timeout
+	}
+}
+
+// NewSQL db的生命周期由调用方管理
+func NewSQL(db *sql.DB, query string, opts ...SQLOption) *SQL {
+	s := &SQL{
+		db:      db,
+		query:   query,
+		timeout: defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+var _ simpleCache.Getter = (*SQL)(nil)
+
+func (s *SQL) Get(key string) ([]byte, error) {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var value []byte
+	err := s.db.QueryRowContext(ctx, s.query, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", key, simpleCache.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%s is null: %w", key, simpleCache.ErrNotFound)
+	}
+	return value, nil
+}
@@ -0,0 +1,49 @@
+//go:build cgo
+
+package getter
+
+import (
+	"database/sql"
+	"errors"
+	"simpleCache"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQL(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// 每个连接都是独立的内存数据库
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		"CREATE TABLE scores (name TEXT PRIMARY KEY, value BLOB)",
+		"INSERT INTO scores VALUES ('Tom', '630'), ('Jack', '589'), ('Nobody', NULL)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewSQL(db, "SELECT value FROM scores WHERE name = ?")
+	for key, want := range map[string]string{"Tom": "630", "Jack": "589"} {
+		v, err := s.Get(key)
+		if err != nil || string(v) != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, v, err, want)
+		}
+	}
+	// 参数化查询, key不会被当成SQL执行
+	for _, key := range []string{"Sam", "Nobody", "' OR '1'='1"} {
+		if _, err = s.Get(key); !errors.Is(err, simpleCache.ErrNotFound) {
+			t.Fatalf("Get(%s) should return ErrNotFound, got %v", key, err)
+		}
+	}
+
+	if _, err = NewSQL(db, "SELECT value FROM missing WHERE name = ?").Get("Tom"); err == nil {
+		t.Fatal("query on missing table should fail")
+	}
+}
@@ -1,5 +1,10 @@
 module simpleCache
 
-go 1.18
+go 1.19
 
-require google.golang.org/protobuf v1.28.1
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/mattn/go-sqlite3 v1.14.16
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -1,21 +1,41 @@
 package simpleCache
 
 import (
+	"bytes"
 	"fmt"
 	"google.golang.org/protobuf/proto"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"simpleCache/consistenthash"
 	"simpleCache/pb"
+	"strconv"
 	"strings"
 	"sync"
 )
 
+// DefaultReplicas HttpPool中每个节点的虚拟节点数
+// 客户端需要用相同的值构建哈希环, 才能找到正确的节点
+const DefaultReplicas = 50
+
 const (
 	defaultBasePath = "/_simplecache"
-	defaultReplicas = 50
+
+	defaultStreamThreshold = 1 << 20
+
+	// 请求方可以解压的编码, 多个编码用逗号分隔
+	acceptEncodingHeader = "X-Accept-Value-Encoding"
+
+	// 请求中带上streamHeader表示可以接收流式返回的数据
+	// 流式返回时响应体就是数据本身, 元数据放在下面几个header中
+	streamHeader  = "X-Value-Stream"
+	versionHeader = "X-Value-Version"
+	ctimeHeader   = "X-Value-Ctime"
+	expireHeader  = "X-Value-Expire"
+	originHeader  = "X-Value-Origin"
+	ctypeHeader   = "X-Value-Content-Type"
 )
 
 // HttpGetter http客户端
@@ -28,52 +48,114 @@ func NewHttpGetter(basePath string) *HttpGetter {
 }
 
 func (g *HttpGetter) GetDataFromPeer(in *pb.Request, out *pb.Response) error {
-	peerUrl := fmt.Sprintf(
-		"%s/%s/%s",
-		g.basePath,
-		url.QueryEscape(in.GetGroup()), // url转义保护
-		url.QueryEscape(in.GetKey()),
-	)
-	resp, err := http.Get(peerUrl)
+	resp, err := g.do(in, false)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("get data from %s failed with status %d", peerUrl, resp.StatusCode)
+	// 知道数据大小时一次分配好内存, 避免ReadAll反复扩容
+	var buf bytes.Buffer
+	if resp.ContentLength > 0 {
+		buf.Grow(int(resp.ContentLength) + bytes.MinRead)
 	}
-
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if _, err = buf.ReadFrom(resp.Body); err != nil {
 		return err
 	}
 
 	// 将得到的结果反序列化到out中
-	if err = proto.Unmarshal(data, out); err != nil {
+	if err = proto.Unmarshal(buf.Bytes(), out); err != nil {
 		return fmt.Errorf("decoding response body failed: %v", err)
 	}
 
 	return nil
 }
 
+// GetStreamFromPeer 以流的方式获取数据, 返回的out中只有元数据, 数据内容从返回的reader中读取
+// 较小的数据对端仍会一次性返回, 这里会包装成reader, 调用方不需要区分
+// 流式返回的数据已经被解压过了, 调用方需要关闭返回的reader
+func (g *HttpGetter) GetStreamFromPeer(in *pb.Request, out *pb.Response) (io.ReadCloser, error) {
+	resp, err := g.do(in, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Header.Get(streamHeader) == "" {
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err = proto.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("decoding response body failed: %v", err)
+		}
+		value := out.Value
+		out.Value = nil
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+
+	out.Version = resp.Header.Get(versionHeader)
+	out.Origin = resp.Header.Get(originHeader)
+	out.Ctime, _ = strconv.ParseInt(resp.Header.Get(ctimeHeader), 10, 64)
+	out.Expire, _ = strconv.ParseInt(resp.Header.Get(expireHeader), 10, 64)
+	out.ContentType = resp.Header.Get(ctypeHeader)
+	return resp.Body, nil
+}
+
+// do 发送请求, 状态码不是200时返回错误
+func (g *HttpGetter) do(in *pb.Request, stream bool) (*http.Response, error) {
+	peerUrl := fmt.Sprintf(
+		"%s/%s/%s",
+		g.basePath,
+		url.QueryEscape(in.GetGroup()), // url转义保护
+		url.QueryEscape(in.GetKey()),
+	)
+	req, err := http.NewRequest(http.MethodGet, peerUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.GetAcceptEncodings()) > 0 {
+		req.Header.Set(acceptEncodingHeader, strings.Join(in.GetAcceptEncodings(), ","))
+	}
+	if stream {
+		req.Header.Set(streamHeader, "1")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get data from %s failed with status %d", peerUrl, resp.StatusCode)
+	}
+	return resp, nil
+}
+
 // HttpPool http服务端
 type HttpPool struct {
 	// 服务端本地信息
-	self     string
-	basePath string
+	self            string
+	basePath        string
+	streamThreshold int // 不小于这个大小的数据在对方支持时以流的方式返回
 
 	// 用于请求远端缓存所需的信息
 	mu          sync.Mutex
 	peers       *consistenthash.Map
+	peerList    []string
 	httpGetters map[string]*HttpGetter
+
+	registry *Registry // 在这里查找请求的group
 }
 
+// NewHttpPool 为DefaultRegistry中的group提供服务
 func NewHttpPool(self string) *HttpPool {
-	return &HttpPool{
-		self:     self,
-		basePath: defaultBasePath,
-	}
+	return DefaultRegistry.NewHttpPool(self)
+}
+
+// SetStreamThreshold 设置以流的方式返回数据的大小阈值
+func (p *HttpPool) SetStreamThreshold(threshold int) {
+	p.streamThreshold = threshold
 }
 
 // Set 会把整个peers设置更新,不保留原数据
@@ -81,8 +163,9 @@ func (p *HttpPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.peers = consistenthash.New(defaultReplicas, nil)
+	p.peers = consistenthash.New(DefaultReplicas, nil)
 	p.peers.Add(peers...)
+	p.peerList = append([]string(nil), peers...)
 
 	// peer的值得是ip+端口
 	p.httpGetters = make(map[string]*HttpGetter, len(peers))
@@ -91,10 +174,36 @@ func (p *HttpPool) Set(peers ...string) {
 	}
 }
 
+// Peers 当前集群中的所有节点
+func (p *HttpPool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.peerList...)
+}
+
+// Self 本节点的地址
+func (p *HttpPool) Self() string {
+	return p.self
+}
+
+// Owner 负责key的节点, 可能是本节点
+func (p *HttpPool) Owner(key string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.peers == nil {
+		return ""
+	}
+	return p.peers.Get(key)
+}
+
 func (p *HttpPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.peers == nil {
+		return nil, false
+	}
 	peerKey := p.peers.Get(key)
 	// 如果找不到负责的peer(这通常是出错了)或是自己负责
 	// PickPeer调用失败,返回false要求本地执行回调去获取数据
@@ -123,7 +232,7 @@ func (p *HttpPool) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	groupName := parts[0]
 	key := parts[1]
 
-	group := GetGroup(groupName)
+	group := p.registry.GetGroup(groupName)
 	if group == nil {
 		http.Error(w, "no such group:"+groupName, 400)
 		return
@@ -135,7 +244,44 @@ func (p *HttpPool) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	resp, err := proto.Marshal(&pb.Response{Value: data.ByteSlice()})
+	// 数据没有变化时不再重复传输
+	etag := strconv.Quote(data.Version())
+	w.Header().Set("ETag", etag)
+	if !data.CreateTime().IsZero() {
+		w.Header().Set("Last-Modified", data.CreateTime().UTC().Format(http.TimeFormat))
+	}
+	if !data.Expire().IsZero() {
+		w.Header().Set("Expires", data.Expire().UTC().Format(http.TimeFormat))
+	}
+	if matchETag(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if req.Header.Get(streamHeader) != "" && data.Len() >= p.streamThreshold {
+		p.serveStream(w, data)
+		return
+	}
+
+	// 请求方支持数据的压缩算法时直接发送压缩后的数据, 否则先解压
+	value, encoding := data.b, data.encoding
+	if encoding != "" && !acceptsEncoding(req.Header.Get(acceptEncodingHeader), encoding) {
+		if value, err = data.decompress(); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		encoding = ""
+	}
+
+	resp, err := proto.Marshal(&pb.Response{
+		Value:       value,
+		Version:     data.Version(),
+		Ctime:       unixNano(data.CreateTime()),
+		Expire:      unixNano(data.Expire()),
+		Origin:      data.Origin(),
+		Encoding:    encoding,
+		ContentType: data.ContentType(),
+	})
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -145,3 +291,37 @@ func (p *HttpPool) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/octet-stream")
 	_, _ = w.Write(resp)
 }
+
+// serveStream 直接把数据写入响应体, 不再整体序列化成pb.Response
+// 没有设置Content-Length, 数据会以chunked的方式发送, 压缩过的数据边解压边发送
+func (p *HttpPool) serveStream(w http.ResponseWriter, data ByteView) {
+	r, err := data.reader()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	defer r.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set(streamHeader, "1")
+	header.Set(versionHeader, data.Version())
+	header.Set(originHeader, data.Origin())
+	header.Set(ctimeHeader, strconv.FormatInt(unixNano(data.CreateTime()), 10))
+	header.Set(expireHeader, strconv.FormatInt(unixNano(data.Expire()), 10))
+	header.Set(ctypeHeader, data.ContentType())
+	if _, err = io.Copy(w, r); err != nil {
+		// 响应头已经发出, 只能中断连接, 否则请求方会把截断的数据当成完整的数据
+		log.Printf("stream %s data failed: %v", data.encoding, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func acceptsEncoding(accept string, encoding string) bool {
+	for _, e := range strings.Split(accept, ",") {
+		if strings.TrimSpace(e) == encoding {
+			return true
+		}
+	}
+	return false
+}
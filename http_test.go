@@ -0,0 +1,50 @@
+package simpleCache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPeerMetadata(t *testing.T) {
+	reg := NewRegistry()
+	owner := newTestGroup(t, reg, "peer-metadata", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("value-" + key), nil
+		}), WithTTL(time.Hour))
+	pool := reg.NewHttpPool("owner")
+	owner.RegisterPeerPicker(pool)
+	server := httptest.NewServer(pool)
+	defer server.Close()
+
+	local, err := owner.Get("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Origin() != "owner" || local.Version() == "" || local.Expire().IsZero() {
+		t.Fatalf("metadata is not filled when loading: %+v", local)
+	}
+
+	remote, err := owner.getFromPeer(NewHttpGetter(server.URL+defaultBasePath), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote.String() != local.String() || remote.Version() != local.Version() ||
+		remote.Origin() != local.Origin() || !remote.Expire().Equal(local.Expire()) ||
+		!remote.CreateTime().Equal(local.CreateTime()) {
+		t.Fatalf("metadata is lost between peers: %+v", remote)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+defaultBasePath+"/peer-metadata/k", nil)
+	req.Header.Set("If-None-Match", strconv.Quote(local.Version()))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional request got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,50 @@
+package simpleCache
+
+// 查看和管理内存缓存内容的接口, 供调试和运维工具使用
+// 这些方法只访问本节点的缓存: 不会加载数据, 不查询其它节点, 也不计入统计信息
+
+// Peek 读取内存中未过期的数据, 不改变淘汰顺序
+func (g *Group) Peek(key string) (ByteView, bool) {
+	return g.mainCache.peek(key)
+}
+
+// Contains 内存中是否有这条未过期的数据, 不改变淘汰顺序
+func (g *Group) Contains(key string) bool {
+	_, ok := g.mainCache.peek(key)
+	return ok
+}
+
+// Keys 按从旧到新的顺序返回内存中所有未过期数据的key
+func (g *Group) Keys() []string {
+	entries := g.mainCache.entries()
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// Range 从最旧到最新遍历内存中未过期的数据, fn返回false时停止遍历
+// 遍历的是调用时的副本, fn中可以调用group的其它方法
+func (g *Group) Range(fn func(key string, value ByteView) bool) {
+	for _, e := range g.mainCache.entries() {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// RangeNewest 和Range相同, 但是从最新到最旧遍历
+func (g *Group) RangeNewest(fn func(key string, value ByteView) bool) {
+	entries := g.mainCache.entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !fn(entries[i].key, entries[i].value) {
+			return
+		}
+	}
+}
+
+// Purge 和Remove一样从内存、磁盘缓存和宽限区中删除本节点的所有数据
+func (g *Group) Purge() {
+	g.mainCache.purge()
+}
@@ -0,0 +1,113 @@
+package simpleCache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	reg := NewRegistry()
+	loads := 0
+	g := newTestGroup(t, reg, "inspect", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte("v" + key), nil
+		}), WithStaleIfError(time.Minute))
+	defer g.Close()
+
+	for _, k := range []string{"k1", "k2", "k3"} {
+		_, _ = g.Get(k)
+	}
+	_ = g.Set("short", []byte("x"), SetTTL(time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	if view, ok := g.Peek("k1"); !ok || view.String() != "vk1" {
+		t.Fatalf("peek k1 got %v", view)
+	}
+	if g.Contains("short") || g.Contains("unknown") {
+		t.Fatal("expired or missing keys should not be contained")
+	}
+	// Peek不改变淘汰顺序
+	if expect := []string{"k1", "k2", "k3"}; !reflect.DeepEqual(g.Keys(), expect) {
+		t.Fatalf("keys got %v, want %v", g.Keys(), expect)
+	}
+	var newest []string
+	g.RangeNewest(func(key string, value ByteView) bool {
+		newest = append(newest, key+"="+value.String())
+		return true
+	})
+	if expect := []string{"k3=vk3", "k2=vk2", "k1=vk1"}; !reflect.DeepEqual(newest, expect) {
+		t.Fatalf("range newest got %v, want %v", newest, expect)
+	}
+	if stats := g.Stats(); stats.Gets != 3 {
+		t.Fatalf("inspecting should not be counted, gets %d", stats.Gets)
+	}
+
+	g.Purge()
+	if len(g.Keys()) != 0 {
+		t.Fatalf("keys after purge: %v", g.Keys())
+	}
+	if _, _ = g.Get("k1"); loads != 4 {
+		t.Fatalf("purged key should be loaded again, loads %d", loads)
+	}
+}
+
+func TestPurgeAllTiers(t *testing.T) {
+	reg := NewRegistry()
+	events := make(eventRecorder, 100)
+	loads := 0
+	// 内存只能放下一条数据, 其余的被挤到磁盘上
+	g := newTestGroup(t, reg, "purge", 5, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte("v" + key), nil
+		}), WithDiskTier(t.TempDir(), 0), WithStaleIfError(time.Minute), WithListener(events.listen))
+	defer g.Close()
+
+	for _, k := range []string{"k1", "k2", "k3"} {
+		_, _ = g.Get(k)
+	}
+	_ = g.Set("old", []byte("x"), SetTTL(time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	// 过期的old被挤出内存后进入宽限区
+	_ = g.Set("k4", []byte("v4"))
+	if g.mainCache.disk.Len() == 0 {
+		t.Fatal("evicted keys should be spilled to disk")
+	}
+	if _, ok := g.mainCache.getStale("old"); !ok {
+		t.Fatal("expired key should be kept in the grace area")
+	}
+	loads = 0
+
+	// 清空之后所有层级都没有数据, 再次读取时需要重新加载
+	g.Purge()
+	if g.mainCache.disk.Len() != 0 {
+		t.Fatalf("disk has %d keys after purge", g.mainCache.disk.Len())
+	}
+	if _, ok := g.mainCache.getStale("old"); ok {
+		t.Fatal("grace area should be empty after purge")
+	}
+	// 只有内存中的数据会产生淘汰事件
+	for {
+		select {
+		case e := <-events:
+			if strings.HasSuffix(e, " removed") && e != "evict k4 removed" {
+				t.Fatalf("unexpected event %q", e)
+			}
+			if e != "evict k4 removed" {
+				continue
+			}
+		case <-time.After(time.Second):
+			t.Fatal("purge should fire a removed event for k4")
+		}
+		break
+	}
+	for _, k := range []string{"k1", "k2", "k3"} {
+		_, _ = g.Get(k)
+	}
+	if loads != 3 {
+		t.Fatalf("purged keys should be loaded again, loads %d", loads)
+	}
+}
@@ -0,0 +1,112 @@
+// Package tcpserver resp和memcache前端共用的TCP服务端, 负责监听和连接的生命周期
+package tcpserver
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// Server 接受连接并交给handler处理, 每个连接一个goroutine
+// handler返回后连接会被关闭
+type Server struct {
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+
+	errClosed error // Close之后Serve返回的错误
+	handler   func(conn net.Conn)
+}
+
+// New errClosed是Close之后Serve返回的错误, 由各个协议的包定义
+func New(errClosed error, handler func(conn net.Conn)) *Server {
+	return &Server{
+		conns:     make(map[net.Conn]struct{}),
+		errClosed: errClosed,
+		handler:   handler,
+	}
+}
+
+// ListenAndServe 监听addr并处理请求, 直到Close被调用
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在l上接受连接, 每个连接一个goroutine
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = l.Close()
+		return s.errClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return s.errClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return s.errClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close 关闭监听和所有连接, 并等待连接处理结束
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		s.wg.Done()
+	}()
+	s.handler(conn)
+}
+
+// OneLine 把\r和\n替换成空格, 放进基于行的协议的错误回复中时不会把一条回复拆成多行
+func OneLine(s string) string {
+	if strings.ContainsAny(s, "\r\n") {
+		s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	}
+	return s
+}
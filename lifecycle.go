@@ -0,0 +1,82 @@
+package simpleCache
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrGroupClosed group已经被Close
+var ErrGroupClosed = errors.New("group is closed")
+
+// Close 从所属的Registry中注销group, 释放内存中的数据并关闭磁盘缓存
+// 磁盘缓存的文件会保留, 快照文件不会被改写
+// 之后Get、Set等调用返回ErrGroupClosed, 正在进行的加载完成后也不会再写入缓存
+// 重复调用Close是安全的
+func (g *Group) Close() error {
+	if !atomic.CompareAndSwapInt32(&g.closed, 0, 1) {
+		return nil
+	}
+	close(g.done)
+	if g.registry != nil {
+		g.registry.remove(g)
+	}
+	return g.mainCache.close()
+}
+
+func (g *Group) isClosed() bool {
+	return atomic.LoadInt32(&g.closed) == 1
+}
+
+// Resize 修改内存缓存的大小上限, 0表示不进行限制
+// 缩小时立即淘汰最旧的数据, 淘汰的数据和平时一样会写入磁盘缓存或宽限区
+func (g *Group) Resize(cacheBytes int64) {
+	g.mainCache.resize(cacheBytes)
+}
+
+// SetPeerPicker 替换group使用的PeerPicker, picker为nil时只从本地数据源加载
+// 可以在运行时多次调用, 正在进行的加载仍然使用旧的PeerPicker
+func (g *Group) SetPeerPicker(picker PeerPicker) {
+	g.peersMu.Lock()
+	defer g.peersMu.Unlock()
+
+	g.setPeerPicker(picker)
+}
+
+func (g *Group) setPeerPicker(picker PeerPicker) {
+	g.peers = picker
+	g.self = defaultOrigin
+	if s, ok := picker.(PeerSelf); ok {
+		g.self = s.Self()
+	}
+}
+
+// SetGetter 替换group的数据源, 已经缓存的数据不受影响
+func (g *Group) SetGetter(getter Getter) {
+	if getter == nil {
+		panic("getter is nil")
+	}
+
+	g.peersMu.Lock()
+	defer g.peersMu.Unlock()
+
+	g.getter = getter
+}
+
+func (g *Group) peerPicker() PeerPicker {
+	g.peersMu.RLock()
+	defer g.peersMu.RUnlock()
+	return g.peers
+}
+
+func (g *Group) currentGetter() Getter {
+	g.peersMu.RLock()
+	defer g.peersMu.RUnlock()
+	return g.getter
+}
+
+// origin 本节点的名字
+func (g *Group) origin() string {
+	g.peersMu.RLock()
+	defer g.peersMu.RUnlock()
+	return g.self
+}
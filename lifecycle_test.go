@@ -0,0 +1,110 @@
+package simpleCache
+
+import (
+	"errors"
+	"simpleCache/pb"
+	"testing"
+)
+
+// staticPeer 总是返回固定数据的peer
+type staticPeer string
+
+func (p staticPeer) GetDataFromPeer(in *pb.Request, out *pb.Response) error {
+	out.Value = []byte(string(p) + "-" + in.Key)
+	return nil
+}
+
+type staticPicker struct {
+	peer PeerGetter
+}
+
+func (p staticPicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, true
+}
+
+func TestGroupClose(t *testing.T) {
+	reg := NewRegistry()
+	g := newTestGroup(t, reg, "lifecycle-close", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithDiskTier(t.TempDir(), 0))
+	_, _ = g.Get("k")
+
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatalf("second Close should be a no-op, got %v", err)
+	}
+	if reg.GetGroup("lifecycle-close") != nil {
+		t.Fatal("closed group should be unregistered")
+	}
+	if st := g.Stats(); st.Items != 0 || st.Bytes != 0 {
+		t.Fatalf("closed group still holds %d items", st.Items)
+	}
+	if _, err := g.Get("k"); !errors.Is(err, ErrGroupClosed) {
+		t.Fatalf("Get on closed group got %v", err)
+	}
+	if err := g.Set("k", []byte("v")); !errors.Is(err, ErrGroupClosed) {
+		t.Fatalf("Set on closed group got %v", err)
+	}
+
+	// 关闭后可以重新创建同名的group
+	newTestGroup(t, reg, "lifecycle-close", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+}
+
+func TestGroupResize(t *testing.T) {
+	g := newTestGroup(t, NewRegistry(), "lifecycle-resize", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("value"), nil
+		}))
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		_, _ = g.Get(k)
+	}
+
+	// 每条数据占7字节, 只能保留最新的两条
+	g.Resize(14)
+	if st := g.Stats(); st.Items != 2 || st.Bytes != 14 {
+		t.Fatalf("after resize got %d items, %d bytes", st.Items, st.Bytes)
+	}
+	if _, ok := g.mainCache.get("k1"); ok {
+		t.Fatal("oldest key should be evicted by Resize")
+	}
+	if _, ok := g.mainCache.get("k4"); !ok {
+		t.Fatal("newest key should be kept by Resize")
+	}
+}
+
+func TestSwapPeerPickerAndGetter(t *testing.T) {
+	g := newTestGroup(t, NewRegistry(), "lifecycle-swap", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("old-" + key), nil
+		}))
+	g.RegisterPeerPicker(staticPicker{peer: staticPeer("peer1")})
+	if v, _ := g.Get("a"); v.String() != "peer1-a" {
+		t.Fatalf("got %s from first picker", v)
+	}
+
+	g.SetPeerPicker(staticPicker{peer: staticPeer("peer2")})
+	if v, _ := g.Get("b"); v.String() != "peer2-b" {
+		t.Fatalf("got %s from swapped picker", v)
+	}
+
+	g.SetPeerPicker(nil)
+	if v, _ := g.Get("c"); v.String() != "old-c" {
+		t.Fatalf("got %s without picker", v)
+	}
+	g.SetGetter(GetterFunc(func(key string) ([]byte, error) {
+		return []byte("new-" + key), nil
+	}))
+	if v, _ := g.Get("d"); v.String() != "new-d" {
+		t.Fatalf("got %s from swapped getter", v)
+	}
+	// 已经缓存的数据不受影响
+	if v, _ := g.Get("c"); v.String() != "old-c" {
+		t.Fatalf("cached value changed to %s", v)
+	}
+}
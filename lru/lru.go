@@ -1,6 +1,15 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"sync/atomic"
+	"unsafe"
+)
+
+// EntryOverhead 估算的每条数据在lru内部额外占用的内存: 链表节点、entry和map中的槽位
+// map槽位按key的字符串头加上指针计算, 实际大小和map的装载率有关
+var EntryOverhead = int64(unsafe.Sizeof(list.Element{}) + unsafe.Sizeof(entry{}) +
+	unsafe.Sizeof("") + unsafe.Sizeof(&list.Element{}))
 
 type Cache struct {
 	// 用于管理缓存空间的大小
@@ -17,6 +26,14 @@ type Cache struct {
 
 	// 一个钩子函数,可以自行设置数据被淘汰时还有什么额外工作需要做
 	OnEvict func(key string, val Value)
+
+	// Overhead 每条数据除key和value之外计入缓存大小的字节数
+	// 默认为0, 只计算key和value的长度, 需要在添加数据之前设置
+	Overhead int64
+
+	// Clock 不为nil时记录每条数据最近一次被访问的时间
+	// 可以用OldestAccess比较不同Cache中数据的新旧
+	Clock func() int64
 }
 
 func New(maxbytes int64, onEvict func(key string, val Value)) *Cache {
@@ -31,8 +48,10 @@ func New(maxbytes int64, onEvict func(key string, val Value)) *Cache {
 
 // 链表节点中存储的数据
 type entry struct {
-	key string
-	val Value
+	key        string
+	val        Value
+	atime      int64 // 最近一次访问的时间, 只在设置了Clock时记录
+	referenced int32 // 通过GetShared访问过, 淘汰时再给一次机会
 }
 
 // Value 用于计算缓存数据的大小
@@ -47,6 +66,7 @@ func (c *Cache) Get(key string) (Value, bool) {
 		c.ll.MoveToFront(ele)
 
 		kv := ele.Value.(*entry)
+		c.touch(kv)
 		return kv.val, true
 	}
 
@@ -54,28 +74,82 @@ func (c *Cache) Get(key string) (Value, bool) {
 	return nil, false
 }
 
+// Peek 读取数据, 不改变数据在队列中的位置和访问时间
+func (c *Cache) Peek(key string) (Value, bool) {
+	if ele, ok := c.cache[key]; ok {
+		return ele.Value.(*entry).val, true
+	}
+	return nil, false
+}
+
+// Contains 判断数据是否存在, 不改变数据在队列中的位置
+func (c *Cache) Contains(key string) bool {
+	_, ok := c.cache[key]
+	return ok
+}
+
+// GetShared 和Get一样读取数据, 但不移动数据在队列中的位置, 只把数据标记为访问过
+// 没有其它写操作同时进行时, 可以被多个goroutine并发调用
+// 被标记的数据淘汰时会被挪到队头再给一次机会(CLOCK算法), 效果近似LRU
+func (c *Cache) GetShared(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if atomic.LoadInt32(&kv.referenced) == 0 {
+		atomic.StoreInt32(&kv.referenced, 1)
+	}
+	if c.Clock != nil {
+		atomic.StoreInt64(&kv.atime, c.Clock())
+	}
+	return kv.val, true
+}
+
 // RemoveOldest 内存淘汰
+// 通过GetShared访问过的数据会被挪到队头, 淘汰下一条
 func (c *Cache) RemoveOldest() {
 	outEle := c.ll.Back()
+	for outEle != nil {
+		kv := outEle.Value.(*entry)
+		if kv.referenced == 0 {
+			break
+		}
+		kv.referenced = 0
+		c.ll.MoveToFront(outEle)
+		outEle = c.ll.Back()
+	}
 	if outEle == nil {
 		return
 	}
 
-	c.ll.Remove(outEle)
-	kv := outEle.Value.(*entry)
-	c.nbytes -= int64(len(kv.key)) + int64(kv.val.Len())
-	delete(c.cache, kv.key)
-
+	kv := c.removeElement(outEle)
 	if c.OnEvict != nil {
 		c.OnEvict(kv.key, kv.val)
 	}
 }
 
+// Remove 从cache中删除数据
+// 主动删除不属于淘汰, 不会触发OnEvict
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+func (c *Cache) removeElement(ele *list.Element) *entry {
+	c.ll.Remove(ele)
+	kv := ele.Value.(*entry)
+	c.nbytes -= c.size(kv.key, kv.val)
+	delete(c.cache, kv.key)
+	return kv
+}
+
 // Add 放入缓存
 func (c *Cache) Add(key string, value Value) {
 	// 避免一个特大的数据把缓存中的数据清空了
 	// 拒绝缓存这样的数据
-	totalBytes := int64(len(key)) + int64(value.Len())
+	totalBytes := c.size(key, value)
 	if totalBytes > c.maxbytes && c.maxbytes != 0 {
 		return
 	}
@@ -84,13 +158,16 @@ func (c *Cache) Add(key string, value Value) {
 		// 找到就修改
 		c.ll.MoveToFront(oldKV)
 		ele := oldKV.Value.(*entry)
+		c.nbytes += int64(value.Len()) - int64(ele.val.Len())
 		ele.val = value
+		c.touch(ele)
 	} else {
 		// 没找到就新建
 		kv := &entry{
 			key: key,
 			val: value,
 		}
+		c.touch(kv)
 		ele := c.ll.PushFront(kv)
 		c.nbytes += totalBytes
 		c.cache[key] = ele
@@ -102,7 +179,86 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+// size 一条数据计入缓存大小的字节数
+func (c *Cache) size(key string, value Value) int64 {
+	return int64(len(key)) + int64(value.Len()) + c.Overhead
+}
+
+func (c *Cache) touch(kv *entry) {
+	if c.Clock != nil {
+		kv.atime = c.Clock()
+	}
+}
+
+// OldestAccess 最旧的一条数据最近一次被访问的时间, 需要设置Clock
+func (c *Cache) OldestAccess() (int64, bool) {
+	ele := c.ll.Back()
+	if ele == nil {
+		return 0, false
+	}
+	return ele.Value.(*entry).atime, true
+}
+
+// Resize 修改缓存空间的上限, 0表示不进行限制
+// 缩小后超出的数据会立即被淘汰, 淘汰时触发OnEvict
+func (c *Cache) Resize(maxbytes int64) {
+	c.maxbytes = maxbytes
+	for c.maxbytes != 0 && c.nbytes > c.maxbytes {
+		c.RemoveOldest()
+	}
+}
+
 // Len 缓存条数
 func (c *Cache) Len() int {
 	return c.ll.Len()
 }
+
+// Bytes 缓存数据占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// Purge 清空缓存, 从最旧到最新依次对每条数据触发OnEvict
+func (c *Cache) Purge() {
+	ll := c.ll
+	c.ll = list.New()
+	c.cache = make(map[string]*list.Element)
+	c.nbytes = 0
+	if c.OnEvict == nil {
+		return
+	}
+	for ele := ll.Back(); ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		c.OnEvict(kv.key, kv.val)
+	}
+}
+
+// Keys 按从旧到新的顺序返回所有key
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.ll.Len())
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		keys = append(keys, ele.Value.(*entry).key)
+	}
+	return keys
+}
+
+// Range 从最旧到最新遍历缓存数据, fn返回false时停止遍历
+// 遍历不会改变数据在队列中的位置
+func (c *Cache) Range(fn func(key string, val Value) bool) {
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		if !fn(kv.key, kv.val) {
+			return
+		}
+	}
+}
+
+// RangeNewest 和Range相同, 但是从最新到最旧遍历
+func (c *Cache) RangeNewest(fn func(key string, val Value) bool) {
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		kv := ele.Value.(*entry)
+		if !fn(kv.key, kv.val) {
+			return
+		}
+	}
+}
@@ -55,3 +55,111 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestResize(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(0, func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+
+	lru.Resize(8)
+	if expect := []string{"k1"}; !reflect.DeepEqual(expect, keys) || lru.Bytes() != 8 {
+		t.Fatalf("Resize evicted %v, %d bytes left", keys, lru.Bytes())
+	}
+	lru.Resize(0)
+	lru.Add("k4", String("v4"))
+	if lru.Len() != 3 {
+		t.Fatalf("Resize to unlimited failed, len %d", lru.Len())
+	}
+}
+
+func TestOldestAccess(t *testing.T) {
+	var now int64
+	lru := New(0, nil)
+	lru.Clock = func() int64 {
+		now++
+		return now
+	}
+	if _, ok := lru.OldestAccess(); ok {
+		t.Fatal("empty cache has no oldest entry")
+	}
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	if atime, _ := lru.OldestAccess(); atime != 1 {
+		t.Fatalf("oldest access time is %d, want 1", atime)
+	}
+	lru.Get("k1")
+	if atime, _ := lru.OldestAccess(); atime != 2 {
+		t.Fatalf("oldest access time after Get is %d, want 2", atime)
+	}
+}
+
+func TestGetShared(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(len("k1v1k2v2k3v3")), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+
+	// 被标记的k1获得第二次机会, 淘汰的是k2
+	if v, ok := lru.GetShared("k1"); !ok || string(v.(String)) != "v1" {
+		t.Fatalf("GetShared k1 failed")
+	}
+	lru.Add("k4", String("v4"))
+	if expect := []string{"k2"}; !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("evicted %v, want %v", keys, expect)
+	}
+	// 第二次机会只有一次, 标记已经被清除
+	lru.Add("k5", String("v5"))
+	lru.Add("k6", String("v6"))
+	lru.Add("k7", String("v7"))
+	if expect := []string{"k2", "k3", "k4", "k1"}; !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("evicted %v, want %v", keys, expect)
+	}
+	if _, ok := lru.GetShared("k2"); ok {
+		t.Fatal("GetShared should miss evicted key")
+	}
+}
+
+func TestPeekPurge(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(int64(len("k1v1k2v2k3v3")), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+
+	// Peek不会把k1挪到队头
+	if v, ok := lru.Peek("k1"); !ok || string(v.(String)) != "v1" || !lru.Contains("k1") {
+		t.Fatal("Peek k1 failed")
+	}
+	if expect := []string{"k1", "k2", "k3"}; !reflect.DeepEqual(expect, lru.Keys()) {
+		t.Fatalf("Keys got %v, want %v", lru.Keys(), expect)
+	}
+	var newest []string
+	lru.RangeNewest(func(key string, val Value) bool {
+		newest = append(newest, key)
+		return len(newest) < 2
+	})
+	if expect := []string{"k3", "k2"}; !reflect.DeepEqual(expect, newest) {
+		t.Fatalf("RangeNewest got %v, want %v", newest, expect)
+	}
+
+	lru.Purge()
+	if expect := []string{"k1", "k2", "k3"}; !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Purge evicted %v, want %v", keys, expect)
+	}
+	if lru.Len() != 0 || lru.Bytes() != 0 || lru.Contains("k1") {
+		t.Fatal("cache should be empty after Purge")
+	}
+	lru.Add("k4", String("v4"))
+	if lru.Len() != 1 {
+		t.Fatal("cache should be usable after Purge")
+	}
+}
@@ -0,0 +1,326 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"simpleCache"
+	"simpleCache/internal/tcpserver"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* memcached协议(文本协议和meta命令)的前端
+ * key的格式为 group:key, 以第一个':'分隔
+ * 支持的命令: get、gets、delete、touch、mg、md、mn、version、quit
+ * 读取会走Group.Get, 也就是会经过peer路由和Getter加载
+ * 删除和touch只作用于本节点, 和Group.Remove/Touch的语义一致
+ */
+
+const (
+	maxKeyLen  = 250 // 和memcached一致
+	maxLineLen = 8 << 10
+
+	// exptime超过30天时表示unix时间戳, 否则表示相对时间(秒)
+	relativeExpireLimit = 60 * 60 * 24 * 30
+)
+
+var ErrServerClosed = errors.New("memcache: server closed")
+
+// Server memcached协议的TCP服务端
+type Server struct {
+	srv *tcpserver.Server // 监听和连接的生命周期
+
+	registry *simpleCache.Registry // 在这里查找请求的group
+}
+
+// NewServer 为simpleCache.DefaultRegistry中的group提供服务
+func NewServer() *Server {
+	return NewServerFor(simpleCache.DefaultRegistry)
+}
+
+// NewServerFor 为r中的group提供服务
+func NewServerFor(r *simpleCache.Registry) *Server {
+	s := &Server{registry: r}
+	s.srv = tcpserver.New(ErrServerClosed, s.serveConn)
+	return s
+}
+
+// ListenAndServe 监听addr并处理请求, 直到Close被调用
+func (s *Server) ListenAndServe(addr string) error {
+	return s.srv.ListenAndServe(addr)
+}
+
+// Serve 在l上接受连接, 每个连接一个goroutine
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// Close 关闭监听和所有连接, 并等待连接处理结束
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// serveConn 处理一个连接上的命令, 返回后连接会被关闭
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReaderSize(conn, maxLineLen)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			_, _ = w.WriteString("CLIENT_ERROR line too long\r\n")
+			_ = w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+		quit := s.execute(w, fields)
+		// 客户端使用pipeline时等读完缓冲区中的命令再统一发送
+		if r.Buffered() == 0 || quit {
+			if err = w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute 执行一条命令, 返回是否需要关闭连接
+func (s *Server) execute(w *bufio.Writer, fields []string) bool {
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "get", "gets":
+		if len(args) == 0 {
+			_, _ = w.WriteString("ERROR\r\n")
+			return false
+		}
+		for _, key := range args {
+			view, err := s.lookup(key)
+			if err != nil {
+				// 文本协议中出错的key和未命中一样直接跳过
+				continue
+			}
+			value := view.String()
+			if cmd == "gets" {
+				fmt.Fprintf(w, "VALUE %s 0 %d %d\r\n", key, len(value), casOf(view))
+			} else {
+				fmt.Fprintf(w, "VALUE %s 0 %d\r\n", key, len(value))
+			}
+			_, _ = w.WriteString(value + "\r\n")
+		}
+		_, _ = w.WriteString("END\r\n")
+	case "delete":
+		noreply := len(args) == 2 && args[1] == "noreply"
+		if len(args) != 1 && !noreply {
+			_, _ = w.WriteString("ERROR\r\n")
+			return false
+		}
+		reply := "NOT_FOUND\r\n"
+		if group, key, err := s.splitKey(args[0]); err != nil {
+			reply = "CLIENT_ERROR " + tcpserver.OneLine(err.Error()) + "\r\n"
+		} else if group.Remove(key) {
+			reply = "DELETED\r\n"
+		}
+		if !noreply {
+			_, _ = w.WriteString(reply)
+		}
+	case "touch":
+		noreply := len(args) == 3 && args[2] == "noreply"
+		if len(args) != 2 && !noreply {
+			_, _ = w.WriteString("ERROR\r\n")
+			return false
+		}
+		reply := "NOT_FOUND\r\n"
+		if group, key, err := s.splitKey(args[0]); err != nil {
+			reply = "CLIENT_ERROR " + tcpserver.OneLine(err.Error()) + "\r\n"
+		} else if ttl, err := parseExptime(args[1]); err != nil {
+			reply = "CLIENT_ERROR invalid exptime argument\r\n"
+		} else if group.Touch(key, ttl) {
+			reply = "TOUCHED\r\n"
+		}
+		if !noreply {
+			_, _ = w.WriteString(reply)
+		}
+	case "mg":
+		s.metaGet(w, args)
+	case "md":
+		s.metaDelete(w, args)
+	case "mn":
+		_, _ = w.WriteString("MN\r\n")
+	case "version":
+		_, _ = w.WriteString("VERSION simplecache\r\n")
+	case "quit":
+		return true
+	default:
+		_, _ = w.WriteString("ERROR\r\n")
+	}
+	return false
+}
+
+// metaGet mg <key> <flag>*
+// 支持的flag: v 返回数据, k 返回key, s 返回大小, c 返回cas, t 返回剩余存活时间,
+// f 返回client flags(总是0), O 原样返回opaque, q 未命中时不回复
+func (s *Server) metaGet(w *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+	key, flags := args[0], args[1:]
+	quiet := hasFlag(flags, 'q')
+
+	view, err := s.lookup(key)
+	if err != nil {
+		if errors.Is(err, simpleCache.ErrNotFound) || errors.Is(err, errBadKey) {
+			if !quiet {
+				_, _ = w.WriteString("EN\r\n")
+			}
+			return
+		}
+		fmt.Fprintf(w, "SERVER_ERROR %s\r\n", tcpserver.OneLine(err.Error()))
+		return
+	}
+
+	value := view.String()
+	var ret []string
+	for _, f := range flags {
+		switch f[0] {
+		case 'k':
+			ret = append(ret, "k"+key)
+		case 's':
+			ret = append(ret, "s"+strconv.Itoa(len(value)))
+		case 'c':
+			ret = append(ret, "c"+strconv.FormatUint(casOf(view), 10))
+		case 'f':
+			ret = append(ret, "f0")
+		case 't':
+			ttl := int64(-1)
+			if !view.Expire().IsZero() {
+				ttl = int64(time.Until(view.Expire()) / time.Second)
+			}
+			ret = append(ret, "t"+strconv.FormatInt(ttl, 10))
+		case 'O':
+			ret = append(ret, f)
+		}
+	}
+
+	if hasFlag(flags, 'v') {
+		fmt.Fprintf(w, "VA %d", len(value))
+		for _, r := range ret {
+			_, _ = w.WriteString(" " + r)
+		}
+		_, _ = w.WriteString("\r\n" + value + "\r\n")
+		return
+	}
+	_, _ = w.WriteString("HD")
+	for _, r := range ret {
+		_, _ = w.WriteString(" " + r)
+	}
+	_, _ = w.WriteString("\r\n")
+}
+
+// metaDelete md <key> <flag>*
+// 支持的flag: q 成功时不回复, O 原样返回opaque, k 返回key
+func (s *Server) metaDelete(w *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+	key, flags := args[0], args[1:]
+	var ret []string
+	for _, f := range flags {
+		switch f[0] {
+		case 'k':
+			ret = append(ret, "k"+key)
+		case 'O':
+			ret = append(ret, f)
+		}
+	}
+
+	status := "NF"
+	if group, k, err := s.splitKey(key); err == nil && group.Remove(k) {
+		status = "HD"
+		if hasFlag(flags, 'q') {
+			return
+		}
+	}
+	_, _ = w.WriteString(status)
+	for _, r := range ret {
+		_, _ = w.WriteString(" " + r)
+	}
+	_, _ = w.WriteString("\r\n")
+}
+
+func hasFlag(flags []string, flag byte) bool {
+	for _, f := range flags {
+		if len(f) > 0 && f[0] == flag {
+			return true
+		}
+	}
+	return false
+}
+
+var errBadKey = errors.New("bad key")
+
+// lookup 读取group:key对应的数据
+func (s *Server) lookup(key string) (simpleCache.ByteView, error) {
+	group, k, err := s.splitKey(key)
+	if err != nil {
+		return simpleCache.ByteView{}, err
+	}
+	return group.Get(k)
+}
+
+// splitKey 把group:key拆分成group和key
+func (s *Server) splitKey(key string) (*simpleCache.Group, string, error) {
+	if len(key) > maxKeyLen {
+		return nil, "", fmt.Errorf("%w: key is too long", errBadKey)
+	}
+	i := strings.IndexByte(key, ':')
+	if i < 0 {
+		return nil, "", fmt.Errorf("%w: key should be in the form group:key", errBadKey)
+	}
+	group := s.registry.GetGroup(key[:i])
+	if group == nil {
+		return nil, "", fmt.Errorf("%w: no such group %s", errBadKey, key[:i])
+	}
+	return group, key[i+1:], nil
+}
+
+// parseExptime 按memcached的规则解析过期时间, 0表示永不过期
+func parseExptime(s string) (time.Duration, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case n == 0:
+		return 0, nil
+	case n < 0:
+		// 负数表示立即过期
+		return time.Nanosecond, nil
+	case n <= relativeExpireLimit:
+		return time.Duration(n) * time.Second, nil
+	default:
+		d := time.Until(time.Unix(n, 0))
+		if d <= 0 {
+			d = time.Nanosecond
+		}
+		return d, nil
+	}
+}
+
+// casOf 数据版本是内容的64位哈希, 直接作为cas
+func casOf(view simpleCache.ByteView) uint64 {
+	cas, _ := strconv.ParseUint(view.Version(), 16, 64)
+	return cas
+}
@@ -0,0 +1,143 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"simpleCache"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var db = map[string]string{
+	"Tom":  "630",
+	"Jack": "589",
+	"Sam":  "567",
+}
+
+// client 纯Go实现的memcached文本协议客户端, 只覆盖测试需要的部分
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *client) line(t *testing.T) string {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// get 发送get/gets命令, 返回key到"value cas"的映射
+func (c *client) get(t *testing.T, cmd string, keys ...string) map[string]string {
+	t.Helper()
+	c.send(t, cmd+" "+strings.Join(keys, " "))
+	res := make(map[string]string)
+	for {
+		line := c.line(t)
+		if line == "END" {
+			return res
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			t.Fatalf("unexpected line %q", line)
+		}
+		n, _ := strconv.Atoi(fields[3])
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			t.Fatal(err)
+		}
+		res[fields[1]] = strings.Join(append([]string{string(buf[:n])}, fields[4:]...), " ")
+	}
+}
+
+func TestServer(t *testing.T) {
+	reg := simpleCache.NewRegistry()
+	g, err := reg.NewGroup("mc-scores", 2<<10, simpleCache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "broken" {
+				return nil, errors.New("database\r\nis down")
+			}
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, simpleCache.ErrNotFound)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServerFor(reg)
+	go s.Serve(l)
+	defer s.Close()
+	c := dial(t, l.Addr().String())
+
+	res := c.get(t, "get", "mc-scores:Tom", "mc-scores:kkk", "unknown:Tom", "mc-scores:Sam")
+	if len(res) != 2 || res["mc-scores:Tom"] != "630" || res["mc-scores:Sam"] != "567" {
+		t.Fatalf("get got %v", res)
+	}
+
+	view, _ := g.Get("Tom")
+	cas, _ := strconv.ParseUint(view.Version(), 16, 64)
+	if res = c.get(t, "gets", "mc-scores:Tom"); res["mc-scores:Tom"] != fmt.Sprintf("630 %d", cas) {
+		t.Fatalf("gets got %v", res)
+	}
+
+	cases := []struct {
+		cmd  string
+		want []string
+	}{
+		{"touch mc-scores:Tom 100", []string{"TOUCHED"}},
+		{"touch mc-scores:nobody 100", []string{"NOT_FOUND"}},
+		{"mg mc-scores:Tom v k t Oabc", []string{"VA 3 kmc-scores:Tom t99 Oabc", "630"}},
+		{"mg mc-scores:Jack s", []string{"HD s3"}},
+		{"mg mc-scores:kkk v", []string{"EN"}},
+		{"md mc-scores:Jack", []string{"HD"}},
+		{"md mc-scores:Jack", []string{"NF"}},
+		{"delete mc-scores:Tom", []string{"DELETED"}},
+		{"delete mc-scores:Tom", []string{"NOT_FOUND"}},
+		{"mn", []string{"MN"}},
+		{"mg mc-scores:broken v", []string{"SERVER_ERROR database  is down"}},
+		{"flush_all", []string{"ERROR"}},
+	}
+	for _, tc := range cases {
+		c.send(t, tc.cmd)
+		for _, want := range tc.want {
+			// 剩余时间可能已经减少了1秒
+			if got := c.line(t); got != want && strings.Replace(got, "t100", "t99", 1) != want {
+				t.Fatalf("%s got %q, want %q", tc.cmd, got, want)
+			}
+		}
+	}
+
+	// q标志: 未命中时没有回复, 用mn确认
+	c.send(t, "mg mc-scores:kkk v q")
+	c.send(t, "mn")
+	if got := c.line(t); got != "MN" {
+		t.Fatalf("quiet miss should not reply, got %q", got)
+	}
+}
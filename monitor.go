@@ -0,0 +1,161 @@
+package simpleCache
+
+import (
+	"errors"
+	"log"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMonitorInterval = time.Second
+	defaultHighWatermark   = 0.85 // 内存用量超过上限的这个比例时开始淘汰
+	defaultCriticalMark    = 0.95 // 超过这个比例时淘汰后立即把内存还给操作系统
+
+	minShrinkStep = 0.05 // 第一次淘汰每个group 5%的数据
+	maxShrinkStep = 0.5  // 压力持续时每次翻倍, 最多一次淘汰一半
+)
+
+// MemoryMonitor 定期读取runtime的内存指标, 接近上限时逐步缩小Registry中所有group的缓存
+// 内存用量按runtime计算GOMEMLIMIT的方式统计, 即进程从操作系统申请且没有归还的内存
+// 压力持续时每次淘汰的比例逐步增大, 压力解除后恢复
+type MemoryMonitor struct {
+	registry *Registry
+	limit    int64
+	interval time.Duration
+	high     float64
+	critical float64
+	usage    func() int64
+
+	mu       sync.Mutex
+	step     float64 // 上一次淘汰的比例, 0表示没有压力
+	pressure float64 // 上一次检查时的内存用量/上限
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// MonitorOption 创建MemoryMonitor时的额外配置
+type MonitorOption func(m *MemoryMonitor)
+
+// WithMonitorInterval 检查内存用量的间隔, 默认1秒
+func WithMonitorInterval(interval time.Duration) MonitorOption {
+	return func(m *MemoryMonitor) {
+		m.interval = interval
+	}
+}
+
+// WithWatermarks 内存用量超过上限的high比例时开始淘汰, 超过critical比例时同时归还内存
+func WithWatermarks(high, critical float64) MonitorOption {
+	return func(m *MemoryMonitor) {
+		m.high = high
+		m.critical = critical
+	}
+}
+
+// NewMemoryMonitor limit为进程的内存上限, 为0时使用runtime当前生效的内存上限(GOMEMLIMIT或debug.SetMemoryLimit)
+// 两者都没有设置时返回错误, 需要调用Start才会开始检查
+func NewMemoryMonitor(r *Registry, limit int64, opts ...MonitorOption) (*MemoryMonitor, error) {
+	if limit == 0 {
+		// 传入负数只读取不修改, 没有设置上限时返回math.MaxInt64
+		if limit = debug.SetMemoryLimit(-1); limit == math.MaxInt64 {
+			limit = 0
+		}
+	}
+	if limit <= 0 {
+		return nil, errors.New("memory limit is not set and GOMEMLIMIT is off")
+	}
+
+	m := &MemoryMonitor{
+		registry: r,
+		limit:    limit,
+		interval: defaultMonitorInterval,
+		high:     defaultHighWatermark,
+		critical: defaultCriticalMark,
+		usage:    runtimeMemoryUsage,
+		stop:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Start 在后台定期检查, 直到Stop被调用
+func (m *MemoryMonitor) Start() {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Check()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查, 可以重复调用
+func (m *MemoryMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Pressure 上一次检查时内存用量和上限的比值
+func (m *MemoryMonitor) Pressure() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pressure
+}
+
+// Check 立即检查一次内存用量, 返回从缓存中淘汰的字节数
+func (m *MemoryMonitor) Check() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used := m.usage()
+	m.pressure = float64(used) / float64(m.limit)
+	if m.pressure < m.high {
+		m.step = 0
+		return 0
+	}
+
+	m.step *= 2
+	if m.step == 0 {
+		m.step = minShrinkStep
+	} else if m.step > maxShrinkStep {
+		m.step = maxShrinkStep
+	}
+
+	var freed int64
+	for _, name := range m.registry.GroupNames() {
+		if g := m.registry.GetGroup(name); g != nil {
+			freed += g.mainCache.shrink(m.step)
+		}
+	}
+	log.Printf("memory usage %d/%d bytes, shrink caches by %.0f%%, %d bytes freed",
+		used, m.limit, m.step*100, freed)
+
+	// 淘汰的数据要等到GC之后才会真正释放
+	if m.pressure >= m.critical {
+		debug.FreeOSMemory()
+	}
+	return freed
+}
+
+// runtimeMemoryUsage 和runtime计算GOMEMLIMIT的方式一致: 所有映射的内存减去已经归还的堆内存
+func runtimeMemoryUsage() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
@@ -0,0 +1,121 @@
+package simpleCache
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestMonitorRuntimeLimit(t *testing.T) {
+	old := debug.SetMemoryLimit(math.MaxInt64)
+	defer debug.SetMemoryLimit(old)
+
+	if _, err := NewMemoryMonitor(NewRegistry(), 0); err == nil {
+		t.Fatalf("monitor without any memory limit should fail")
+	}
+	debug.SetMemoryLimit(512 << 20)
+	m, err := NewMemoryMonitor(NewRegistry(), 0)
+	if err != nil || m.limit != 512<<20 {
+		t.Fatalf("monitor should use the runtime memory limit, got %v", err)
+	}
+}
+
+func TestMemoryMonitor(t *testing.T) {
+	reg := NewRegistry()
+	g := newTestGroup(t, reg, "monitor", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("12345678"), nil
+		}))
+	for i := 0; i < 100; i++ {
+		_, _ = g.Get(fmt.Sprintf("k%02d", i))
+	}
+
+	m, err := NewMemoryMonitor(reg, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var used int64
+	m.usage = func() int64 { return used }
+
+	used = 800
+	if freed := m.Check(); freed != 0 || g.Stats().Items != 100 {
+		t.Fatalf("no eviction expected below the high watermark, freed %d", freed)
+	}
+
+	// 压力持续时淘汰比例逐步增大: 5%, 10%, 20%
+	used = 900
+	for _, want := range []int{95, 85, 68} {
+		m.Check()
+		if items := g.Stats().Items; items != want {
+			t.Fatalf("got %d items, want %d", items, want)
+		}
+	}
+	if _, ok := g.mainCache.get("k99"); !ok {
+		t.Fatal("the newest key should be kept")
+	}
+
+	// 压力解除后从最小比例重新开始
+	used = 500
+	m.Check()
+	used = 990
+	m.Check()
+	if items := g.Stats().Items; items != 64 {
+		t.Fatalf("got %d items after pressure is back, want 64", items)
+	}
+	if m.Pressure() != 0.99 {
+		t.Fatalf("pressure is %v", m.Pressure())
+	}
+}
+
+func TestEntryOverhead(t *testing.T) {
+	reg := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	plain := newTestGroup(t, reg, "overhead-plain", 0, getter)
+	counted := newTestGroup(t, reg, "overhead-counted", 0, getter, WithEntryOverhead())
+	_, _ = plain.Get("k")
+	_, _ = counted.Get("k")
+
+	if plain.Stats().Bytes != 2 {
+		t.Fatalf("plain group counts %d bytes, want 2", plain.Stats().Bytes)
+	}
+	if counted.Stats().Bytes != 2+entryOverhead || entryOverhead < 100 {
+		t.Fatalf("counted group counts %d bytes, overhead %d", counted.Stats().Bytes, entryOverhead)
+	}
+}
+
+func TestShrinkReleasesMemory(t *testing.T) {
+	g := newTestGroup(t, NewRegistry(), "monitor-shrink", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return make([]byte, 100), nil
+		}), WithStaleIfError(time.Hour), WithDiskTier(t.TempDir(), 0))
+	// 超出容量的数据被写入磁盘, 这里只关心内存中保留的数据
+	for i := 0; i < 100; i++ {
+		_, _ = g.Get(fmt.Sprintf("k%02d", i))
+	}
+	c := &g.mainCache
+	retained := func() int64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.mem.Bytes() + c.grace.Bytes()
+	}
+	diskLen := c.disk.Len()
+	c.mu.Lock()
+	c.retire("old", ByteView{b: make([]byte, 100)})
+	c.mu.Unlock()
+
+	before := retained()
+	freed := c.shrink(0.5)
+	if freed == 0 || retained() != before-freed {
+		t.Fatalf("shrink reported %d bytes freed, but retained bytes went from %d to %d", freed, before, retained())
+	}
+	if c.disk.Len() != diskLen {
+		t.Fatalf("shrink should not spill entries to disk")
+	}
+	if c.grace.Len() != 0 {
+		t.Fatalf("shrink should trim the grace area")
+	}
+}
@@ -0,0 +1,181 @@
+package simpleCache
+
+import (
+	"log"
+	"simpleCache/disk"
+	"time"
+)
+
+// Option 在NewGroup时对Group进行额外的配置
+// 不传任何Option时Group的行为和之前保持一致
+type Option func(g *Group)
+
+// WithTTL 设置从数据源载入的数据在缓存中的存活时间, 0表示永不过期
+func WithTTL(ttl time.Duration) Option {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithSoftTTL 设置数据的软过期时间, 应当小于WithTTL设置的硬过期时间
+// 超过软过期时间的数据仍会被直接返回, 同时在后台重新加载
+// 超过硬过期时间的数据则必须等待重新加载完成
+func WithSoftTTL(softTTL time.Duration) Option {
+	return func(g *Group) {
+		g.softTTL = softTTL
+	}
+}
+
+// WithRefreshAhead 对热点数据进行提前刷新
+// 命中次数达到minHits, 且距离(软)过期不足window的数据会在后台重新加载
+// 这样热点数据几乎不会因为过期而让调用方阻塞
+func WithRefreshAhead(window time.Duration, minHits int64) Option {
+	return func(g *Group) {
+		g.refreshWindow = window
+		g.refreshMinHits = minHits
+	}
+}
+
+// WithStaleIfError 保留过期或被淘汰的数据, 重新加载失败时返回这些陈旧数据
+// 返回的数据ByteView.Stale()为true, 陈旧时间超过maxStale的数据不会再被使用
+// 宽限区和主缓存使用相同的空间上限
+func WithStaleIfError(maxStale time.Duration) Option {
+	return func(g *Group) {
+		g.mainCache.maxStale = maxStale
+	}
+}
+
+// WithCompression 对不小于threshold字节的数据使用c进行压缩
+// 缓存空间按压缩后的大小计算, 读取数据内容时才会解压
+// c需要通过RegisterCompressor注册, 其他节点也需要注册同名的算法才能直接接收压缩后的数据
+func WithCompression(c Compressor, threshold int) Option {
+	return func(g *Group) {
+		g.compressor = c
+		g.compressThreshold = threshold
+	}
+}
+
+// WithSnapshotFile 在NewGroup时从path载入快照
+// 并且每隔interval把缓存内容写回path, interval为0时不做定期快照
+func WithSnapshotFile(path string, interval time.Duration) Option {
+	return func(g *Group) {
+		g.snapshotPath = path
+		g.snapshotInterval = interval
+	}
+}
+
+// WithDiskTier 在内存缓存之下增加一层位于dir的磁盘缓存
+// 从内存中淘汰的数据会写入磁盘, 内存未命中时先查磁盘再去加载数据
+// maxBytes限制磁盘中有效数据的大小, 0时不进行限制
+// 磁盘缓存打开失败时只打印日志, group退化为只使用内存缓存
+func WithDiskTier(dir string, maxBytes int64) Option {
+	return func(g *Group) {
+		store, err := disk.Open(dir, maxBytes)
+		if err != nil {
+			log.Printf("open disk tier %s for group %s failed: %v", dir, g.name, err)
+			return
+		}
+		g.mainCache.disk = store
+	}
+}
+
+// WithBudget 让group加入共享的内存预算b
+// reserve是保留额度, group的内存用量不超过它时不会因为预算被淘汰
+// weight是权重, 不小于1, 越大的group数据保留得越久
+func WithBudget(b *Budget, reserve int64, weight int) Option {
+	return func(g *Group) {
+		if weight < 1 {
+			weight = 1
+		}
+		g.mainCache.budget = b
+		g.mainCache.reserve = reserve
+		g.mainCache.weight = weight
+		b.join(&g.mainCache)
+	}
+}
+
+// WithEntryOverhead 缓存大小除了key和value的长度, 还计入每条数据的固定内存开销
+// 数据较小时实际占用的内存远大于key和value的长度, 打开后cacheBytes更接近真实的内存用量
+func WithEntryOverhead() Option {
+	return func(g *Group) {
+		g.mainCache.countOverhead = true
+	}
+}
+
+// WithSlabStorage 内存缓存使用slab存储引擎, 缓冲区按cacheBytes一次性分配
+// 所有数据保存在不含指针的大块内存中, 缓存大量小数据时可以显著减少GC的扫描时间
+// 代价是每次读取都要解码并拷贝数据, 并且refresh-ahead不会生效
+// cacheBytes必须大于0, 否则仍然使用lru
+func WithSlabStorage() Option {
+	return func(g *Group) {
+		if g.mainCache.cacheBytes <= 0 {
+			log.Printf("slab storage of group %s needs a positive cacheBytes, fall back to lru", g.name)
+			return
+		}
+		g.mainCache.useSlab = true
+	}
+}
+
+// WithConcurrentReads 命中缓存时只需要读锁, 多个goroutine可以同时读取
+// 命中不再把数据挪到队头, 只做访问标记, 淘汰时被标记的数据会再获得一次机会(CLOCK算法)
+// 淘汰顺序近似LRU, 适合命中率高、读多写少的场景; 使用slab存储引擎时不生效
+func WithConcurrentReads() Option {
+	return func(g *Group) {
+		g.mainCache.sharedReads = true
+	}
+}
+
+// WithBatching 开启批量加载, 只对实现了BatchGetter的回调函数生效
+// 本地未命中的key最多等待window, 期间其它未命中的key会合并到同一次GetBatch中,
+// 凑够maxBatch个key时立即加载; window和maxBatch不大于0时分别使用1ms和100
+func WithBatching(window time.Duration, maxBatch int) Option {
+	return func(g *Group) {
+		if window <= 0 {
+			window = defaultBatchWindow
+		}
+		if maxBatch <= 0 {
+			maxBatch = defaultMaxBatch
+		}
+		g.batcher = &batcher{window: window, maxBatch: maxBatch, stats: &g.stats}
+	}
+}
+
+// WithListener 注册接收group事件的Listener, 可以注册多个
+// 事件先放进有界队列, 再由group专属的goroutine投递, 慢的Listener不会阻塞Get
+// 队列满时新的事件会被丢弃, 丢弃的数量记录在Stats.EventsDropped中
+func WithListener(l Listener) Option {
+	return func(g *Group) {
+		g.listeners = append(g.listeners, l)
+	}
+}
+
+// WithEventQueueSize 设置事件队列的长度, 默认1024
+func WithEventQueueSize(size int) Option {
+	return func(g *Group) {
+		if size > 0 {
+			g.eventQueueSize = size
+		}
+	}
+}
+
+// SetOption 调用Group.Set时的额外配置
+type SetOption func(o *setOptions)
+
+type setOptions struct {
+	ttl         time.Duration
+	contentType string
+}
+
+// SetTTL 指定这条数据的存活时间, 覆盖group的默认配置, 0表示永不过期
+func SetTTL(ttl time.Duration) SetOption {
+	return func(o *setOptions) {
+		o.ttl = ttl
+	}
+}
+
+// SetContentType 指定数据的Content-Type, 通过API读取时原样返回
+func SetContentType(contentType string) SetOption {
+	return func(o *setOptions) {
+		o.contentType = contentType
+	}
+}
@@ -1,6 +1,9 @@
 package simpleCache
 
-import "simpleCache/pb"
+import (
+	"io"
+	"simpleCache/pb"
+)
 
 // 默认的实现在http.go
 // 使用者可以自己实现对应的接口达到扩展功能的目的
@@ -17,3 +20,28 @@ type PeerPicker interface {
 type PeerGetter interface {
 	GetDataFromPeer(in *pb.Request, out *pb.Response) error
 }
+
+// StreamPeerGetter PeerGetter可以选择实现
+// 以流的方式从peer获取较大的数据, out中只填充元数据
+type StreamPeerGetter interface {
+	GetStreamFromPeer(in *pb.Request, out *pb.Response) (io.ReadCloser, error)
+}
+
+// PeerSelf PeerPicker可以选择实现
+// 返回本节点的名字, 用于标记数据是由哪个节点载入的
+type PeerSelf interface {
+	Self() string
+}
+
+// PeerLister 可以列出集群成员的PeerPicker可以选择实现, APIHandler用它提供/peers接口
+type PeerLister interface {
+	PeerSelf
+	Peers() []string
+}
+
+// PeerOwner PeerPicker可以选择实现
+// 返回负责key的节点名字, 没有成员信息时返回空字符串
+// APIHandler用它把写请求重定向到负责key的节点
+type PeerOwner interface {
+	Owner(key string) string
+}
@@ -0,0 +1,56 @@
+package simpleCache
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// needRefresh 判断一条命中的数据是否需要在后台重新加载
+func (g *Group) needRefresh(value ByteView, now time.Time) bool {
+	if value.ctime.IsZero() {
+		return false
+	}
+
+	// 超过软过期时间: stale-while-revalidate
+	var deadline time.Time
+	if g.softTTL > 0 {
+		deadline = value.ctime.Add(g.softTTL)
+		if !now.Before(deadline) {
+			return true
+		}
+	} else {
+		deadline = value.expire
+	}
+
+	// 热点数据快要过期时提前刷新: refresh-ahead
+	if g.refreshWindow <= 0 || deadline.IsZero() || value.hits == nil {
+		return false
+	}
+	hits := atomic.AddInt64(value.hits, 1)
+	return hits >= g.refreshMinHits && deadline.Sub(now) <= g.refreshWindow
+}
+
+// refreshAsync 在后台重新加载key, 同一个key同时只会有一个刷新任务
+// 刷新失败时保留旧数据, 直到它硬过期
+func (g *Group) refreshAsync(key string) {
+	g.refreshMu.Lock()
+	if _, ok := g.refreshing[key]; ok {
+		g.refreshMu.Unlock()
+		return
+	}
+	g.refreshing[key] = struct{}{}
+	g.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			g.refreshMu.Lock()
+			delete(g.refreshing, key)
+			g.refreshMu.Unlock()
+		}()
+
+		if _, err := g.load(key); err != nil {
+			log.Printf("refresh key %s of group %s failed: %v", key, g.name, err)
+		}
+	}()
+}
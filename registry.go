@@ -0,0 +1,90 @@
+package simpleCache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrGroupExists 同一个Registry中已经有同名的group
+var ErrGroupExists = errors.New("group already exists")
+
+// Registry 管理一组group, 不同的Registry之间互不影响
+// 同一个进程中可以运行多个独立的缓存, 测试之间也不会共享状态
+// 包级别的NewGroup、GetGroup等函数使用DefaultRegistry
+type Registry struct {
+	mu     sync.RWMutex
+	groups map[string]*Group
+}
+
+// DefaultRegistry 包级别函数使用的默认实例
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{groups: make(map[string]*Group)}
+}
+
+// NewGroup 创建group并注册到r中, 同名的group已经存在时返回ErrGroupExists
+func (r *Registry) NewGroup(name string, cacheBytes int64, getter Getter, opts ...Option) (*Group, error) {
+	if getter == nil {
+		return nil, errors.New("getter is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+	g := newGroup(name, cacheBytes, getter, opts...)
+	g.registry = r
+	r.groups[name] = g
+	return g, nil
+}
+
+// remove 注销g, 同名的group已经被替换时不做任何事
+func (r *Registry) remove(g *Group) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.groups[g.name] == g {
+		delete(r.groups, g.name)
+	}
+}
+
+// GetGroup 对应group不存在时返回nil
+func (r *Registry) GetGroup(name string) *Group {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.groups[name]
+}
+
+// GroupNames 所有group的名字, 按字典序排列
+func (r *Registry) GroupNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewHttpPool 创建只为r中的group提供服务的HttpPool
+func (r *Registry) NewHttpPool(self string) *HttpPool {
+	return &HttpPool{
+		self:            self,
+		basePath:        defaultBasePath,
+		streamThreshold: defaultStreamThreshold,
+		registry:        r,
+	}
+}
+
+// NewAPIHandler 创建只操作r中group的APIHandler
+func (r *Registry) NewAPIHandler() *APIHandler {
+	return &APIHandler{registry: r}
+}
@@ -0,0 +1,59 @@
+package simpleCache
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"simpleCache/pb"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	getter := func(prefix string) Getter {
+		return GetterFunc(func(key string) ([]byte, error) {
+			return []byte(prefix + key), nil
+		})
+	}
+
+	r1, r2 := NewRegistry(), NewRegistry()
+	g1 := newTestGroup(t, r1, "registry", 2<<10, getter("r1-"))
+	newTestGroup(t, r2, "registry", 2<<10, getter("r2-"))
+	newTestGroup(t, r2, "registry-other", 2<<10, getter("r2-"))
+
+	if _, err := r1.NewGroup("registry", 2<<10, getter("dup-")); !errors.Is(err, ErrGroupExists) {
+		t.Fatalf("duplicate group should return ErrGroupExists, got %v", err)
+	}
+	if r1.GetGroup("registry") != g1 {
+		t.Fatal("duplicate group should not replace the existing one")
+	}
+	if r1.GetGroup("registry-other") != nil || DefaultRegistry.GetGroup("registry") != nil {
+		t.Fatal("groups should not leak between registries")
+	}
+	if names := r2.GroupNames(); !reflect.DeepEqual(names, []string{"registry", "registry-other"}) {
+		t.Fatalf("group names of r2 are %v", names)
+	}
+
+	// HttpPool只为自己所属Registry中的group提供服务
+	for prefix, r := range map[string]*Registry{"r1-": r1, "r2-": r2} {
+		server := httptest.NewServer(r.NewHttpPool("owner"))
+		resp := &pb.Response{}
+		err := NewHttpGetter(server.URL+defaultBasePath).GetDataFromPeer(&pb.Request{Group: "registry", Key: "k"}, resp)
+		server.Close()
+		if err != nil || string(resp.Value) != prefix+"k" {
+			t.Fatalf("pool of %s got %q, %v", prefix, resp.Value, err)
+		}
+	}
+}
+
+func TestNewGroupDuplicatePanics(t *testing.T) {
+	name := "registry-default-dup"
+	if DefaultRegistry.GetGroup(name) == nil {
+		NewGroup(name, 2<<10, GetterFunc(func(key string) ([]byte, error) { return nil, nil }))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewGroup with a duplicate name should panic")
+		}
+	}()
+	NewGroup(name, 2<<10, GetterFunc(func(key string) ([]byte, error) { return nil, nil }))
+}
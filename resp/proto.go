@@ -0,0 +1,158 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"simpleCache/internal/tcpserver"
+	"strconv"
+	"strings"
+)
+
+/* RESP2协议的编解码
+ * 请求是由bulk string组成的数组, 也兼容telnet等工具发送的inline命令
+ * 回复支持simple string、error、integer、bulk string和array
+ */
+
+const (
+	maxBulkLen  = 512 << 20 // 和redis一致, 单个bulk string最大512MB
+	maxArrayLen = 1 << 20
+
+	// bulk string较大时初始分配的缓冲区大小
+	bulkChunk = 64 << 10
+)
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand 读取一条命令, 返回命令名和参数
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	// inline命令: 用空格分隔的参数
+	if line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, f := range fields {
+			args[i] = []byte(f)
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > maxArrayLen {
+		return nil, errProtocol
+	}
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+		buf, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, buf)
+	}
+	return args, nil
+}
+
+// readBulk 读取size字节的bulk string和结尾的\r\n
+// 内存随着实际收到的数据增长, 客户端只声明长度不发送数据时不会一次性分配size字节
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if size+2 <= bulkChunk {
+		buf.Grow(size + 2)
+	} else {
+		buf.Grow(bulkChunk)
+	}
+	if _, err := io.CopyN(&buf, r, int64(size+2)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	b := buf.Bytes()
+	if b[size] != '\r' || b[size+1] != '\n' {
+		return nil, errProtocol
+	}
+	return b[:size], nil
+}
+
+// readLine 读取以\r\n结尾的一行, 返回的内容不包括\r\n
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errProtocol
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+// writer 回复的编码, 出错后忽略后续的写入, 由调用方在Flush时统一处理
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) write(format string, args ...any) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
+
+func (w *writer) simple(s string) {
+	w.write("+%s\r\n", s)
+}
+
+// error 错误信息中的\r\n会被替换成空格, 避免一条回复被拆成多行
+func (w *writer) error(s string) {
+	w.write("-%s\r\n", tcpserver.OneLine(s))
+}
+
+func (w *writer) integer(n int64) {
+	w.write(":%d\r\n", n)
+}
+
+func (w *writer) bulk(b []byte) {
+	w.write("$%d\r\n", len(b))
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+	w.write("\r\n")
+}
+
+func (w *writer) null() {
+	w.write("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.write("*%d\r\n", n)
+}
+
+func (w *writer) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
@@ -0,0 +1,245 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"simpleCache"
+	"simpleCache/internal/tcpserver"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Redis协议(RESP2)的前端, 让redis客户端和redis-cli可以直接读取缓存
+ * key的格式为 group:key, 以第一个':'分隔
+ * 支持的命令: GET、MGET、SET(EX/PX)、DEL、PING、INFO、COMMAND、QUIT
+ * 读取会走Group.Get, 也就是会经过peer路由和Getter加载
+ * 写入和删除只作用于本节点, 和Group.Set/Remove的语义一致
+ */
+
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Server RESP协议的TCP服务端
+type Server struct {
+	srv *tcpserver.Server // 监听和连接的生命周期
+
+	registry *simpleCache.Registry // 在这里查找请求的group
+}
+
+// NewServer 为simpleCache.DefaultRegistry中的group提供服务
+func NewServer() *Server {
+	return NewServerFor(simpleCache.DefaultRegistry)
+}
+
+// NewServerFor 为r中的group提供服务
+func NewServerFor(r *simpleCache.Registry) *Server {
+	s := &Server{registry: r}
+	s.srv = tcpserver.New(ErrServerClosed, s.serveConn)
+	return s
+}
+
+// ListenAndServe 监听addr并处理请求, 直到Close被调用
+func (s *Server) ListenAndServe(addr string) error {
+	return s.srv.ListenAndServe(addr)
+}
+
+// Serve 在l上接受连接, 每个连接一个goroutine
+func (s *Server) Serve(l net.Listener) error {
+	return s.srv.Serve(l)
+}
+
+// Close 关闭监听和所有连接, 并等待连接处理结束
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// serveConn 处理一个连接上的命令, 返回后连接会被关闭
+func (s *Server) serveConn(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := &writer{w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err == errProtocol {
+				w.error("ERR Protocol error")
+				_ = w.flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(w, args)
+		// 客户端使用pipeline时等读完缓冲区中的命令再统一发送
+		if r.Buffered() == 0 || quit {
+			if err = w.flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute 执行一条命令, 返回是否需要关闭连接
+func (s *Server) execute(w *writer, args [][]byte) bool {
+	cmd := strings.ToUpper(string(args[0]))
+	args = args[1:]
+
+	switch cmd {
+	case "PING":
+		switch len(args) {
+		case 0:
+			w.simple("PONG")
+		case 1:
+			w.bulk(args[0])
+		default:
+			wrongArgs(w, cmd)
+		}
+	case "GET":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			return false
+		}
+		s.get(w, string(args[0]))
+	case "MGET":
+		if len(args) == 0 {
+			wrongArgs(w, cmd)
+			return false
+		}
+		w.array(len(args))
+		for _, key := range args {
+			// MGET中单个key出错时和redis一样返回nil, 不影响其他key
+			if value, err := s.lookup(string(key)); err == nil {
+				w.bulk(value)
+			} else {
+				w.null()
+			}
+		}
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		if len(args) == 0 {
+			wrongArgs(w, cmd)
+			return false
+		}
+		var n int64
+		for _, key := range args {
+			group, k, err := s.splitKey(string(key))
+			if err == nil && group.Remove(k) {
+				n++
+			}
+		}
+		w.integer(n)
+	case "INFO":
+		w.bulk([]byte(s.info()))
+	case "COMMAND":
+		// redis-cli连接时会发送COMMAND DOCS, 返回空数组即可
+		w.array(0)
+	case "QUIT":
+		w.simple("OK")
+		return true
+	default:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", cmd))
+	}
+	return false
+}
+
+func (s *Server) get(w *writer, key string) {
+	value, err := s.lookup(key)
+	switch {
+	case err == nil:
+		w.bulk(value)
+	case errors.Is(err, simpleCache.ErrNotFound):
+		w.null()
+	default:
+		w.error("ERR " + err.Error())
+	}
+}
+
+// set SET key value [EX seconds | PX milliseconds]
+func (s *Server) set(w *writer, args [][]byte) {
+	if len(args) != 2 && len(args) != 4 {
+		wrongArgs(w, "SET")
+		return
+	}
+	group, key, err := s.splitKey(string(args[0]))
+	if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+
+	var opts []simpleCache.SetOption
+	if len(args) == 4 {
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil || n <= 0 {
+			w.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		switch strings.ToUpper(string(args[2])) {
+		case "EX":
+			opts = append(opts, simpleCache.SetTTL(time.Duration(n)*time.Second))
+		case "PX":
+			opts = append(opts, simpleCache.SetTTL(time.Duration(n)*time.Millisecond))
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if err = group.Set(key, args[1], opts...); err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.simple("OK")
+}
+
+// lookup 读取group:key对应的数据
+func (s *Server) lookup(key string) ([]byte, error) {
+	group, k, err := s.splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	view, err := group.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	return view.ByteSlice(), nil
+}
+
+// splitKey 把group:key拆分成group和key
+func (s *Server) splitKey(key string) (*simpleCache.Group, string, error) {
+	i := strings.IndexByte(key, ':')
+	if i < 0 {
+		return nil, "", fmt.Errorf("key %q should be in the form group:key", key)
+	}
+	group := s.registry.GetGroup(key[:i])
+	if group == nil {
+		return nil, "", fmt.Errorf("no such group: %s", key[:i])
+	}
+	return group, key[i+1:], nil
+}
+
+// info INFO命令的输出, 格式和redis一致, 每个group一行
+func (s *Server) info() string {
+	var b strings.Builder
+	b.WriteString("# Server\r\nsimplecache_mode:resp\r\n\r\n# Groups\r\n")
+	for _, name := range s.registry.GroupNames() {
+		g := s.registry.GetGroup(name)
+		if g == nil {
+			continue
+		}
+		st := g.Stats()
+		fmt.Fprintf(&b, "%s:gets=%d,hits=%d,loads=%d,peer_loads=%d,items=%d,bytes=%d\r\n",
+			name, st.Gets, st.Hits, st.Loads, st.PeerLoads, st.Items, st.Bytes)
+	}
+	return b.String()
+}
+
+func wrongArgs(w *writer, cmd string) {
+	w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
@@ -0,0 +1,148 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"simpleCache"
+	"strings"
+	"testing"
+)
+
+var db = map[string]string{
+	"Tom":  "630",
+	"Jack": "589",
+	"Sam":  "567",
+}
+
+// client 按RESP协议发送命令并读取原始回复
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	return c.reply(t)
+}
+
+// reply 读取一条完整的回复, 多行回复用空格连接
+func (c *client) reply(t *testing.T) string {
+	t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '$':
+		var n int
+		fmt.Sscan(line[1:], &n)
+		if n < 0 {
+			return "(nil)"
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, buf); err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	case '*':
+		var n int
+		fmt.Sscan(line[1:], &n)
+		items := make([]string, n)
+		for i := range items {
+			items[i] = c.reply(t)
+		}
+		return strings.Join(items, " ")
+	}
+	return line
+}
+
+func TestServer(t *testing.T) {
+	reg := simpleCache.NewRegistry()
+	_, err := reg.NewGroup("resp-scores", 2<<10, simpleCache.GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "broken" {
+				return nil, errors.New("database\r\nis down")
+			}
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("%s not exist: %w", key, simpleCache.ErrNotFound)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServerFor(reg)
+	go s.Serve(l)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "+PONG"},
+		{[]string{"GET", "resp-scores:Tom"}, "630"},
+		{[]string{"GET", "resp-scores:kkk"}, "(nil)"},
+		{[]string{"GET", "unknown:Tom"}, "-ERR no such group: unknown"},
+		{[]string{"GET", "resp-scores:broken"}, "-ERR database  is down"},
+		{[]string{"MGET", "resp-scores:Tom", "resp-scores:kkk", "resp-scores:Sam"}, "630 (nil) 567"},
+		{[]string{"SET", "resp-scores:Amy", "700", "EX", "60"}, "+OK"},
+		{[]string{"GET", "resp-scores:Amy"}, "700"},
+		{[]string{"DEL", "resp-scores:Amy", "resp-scores:nobody"}, ":1"},
+		{[]string{"SET", "resp-scores:Amy", "700", "EX", "0"}, "-ERR invalid expire time in 'set' command"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"FLUSHALL"}, "-ERR unknown command 'FLUSHALL'"},
+	}
+	for _, tc := range cases {
+		if got := c.do(t, tc.args...); got != tc.want {
+			t.Fatalf("%v got %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	if got := c.do(t, "INFO"); !strings.Contains(got, "resp-scores:gets=") {
+		t.Fatalf("INFO should contain group stats, got %q", got)
+	}
+
+	// inline命令
+	_, _ = conn.Write([]byte("PING hello\r\n"))
+	if got := c.reply(t); got != "hello" {
+		t.Fatalf("inline PING got %q", got)
+	}
+}
+
+func TestLargeBulkHeader(t *testing.T) {
+	// 只声明了512MB的长度, 实际只发送了几个字节
+	r := bufio.NewReader(strings.NewReader("*1\r\n$536870000\r\nabc"))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := readCommand(r); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated bulk got %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Fatalf("declared bulk length should not be allocated up front, allocated %d bytes", n)
+	}
+}
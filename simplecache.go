@@ -2,16 +2,22 @@ package simpleCache
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"os"
 	"simpleCache/pb"
 	"simpleCache/singleflight"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var (
-	mu     sync.RWMutex
-	groups = make(map[string]*Group)
-)
+// ErrNotFound Getter可以返回(或包装)这个错误, 表示数据源中不存在这个key
+// API等对外接口会据此返回404
+var ErrNotFound = errors.New("key not found")
+
+// defaultOrigin 未注册PeerPicker时用主机名标识本节点
+var defaultOrigin, _ = os.Hostname()
 
 // Getter 由用户传入的回调函数:如何从数据源拉取数据
 type Getter interface {
@@ -27,56 +33,108 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 // Group 核心结构
 type Group struct {
 	name      string              // 命名空间
-	getter    Getter              // 回调函数
 	mainCache cache               // 属于这个group的缓存
-	peers     PeerPicker          // 以此获取远端缓存
 	loader    *singleflight.Group // 合并重复查询请求,防止缓存击穿
+	stats     groupStats          // 统计信息
+	registry  *Registry           // group所属的Registry, Close时从中注销
+
+	// getter、peers和self可以在运行时替换, 读写都需要持有peersMu
+	peersMu sync.RWMutex
+	getter  Getter     // 回调函数
+	peers   PeerPicker // 以此获取远端缓存
+	self    string     // 本节点的名字, 记录在载入的数据中
+
+	closed int32         // Close之后为1
+	done   chan struct{} // Close时关闭, 通知后台任务退出
+
+	ttl               time.Duration // 数据的存活时间, 0表示永不过期
+	softTTL           time.Duration // 超过这个时间的数据仍然可用, 但会在后台重新加载
+	refreshWindow     time.Duration // 距离过期不足这个时间的热点数据会被提前刷新
+	refreshMinHits    int64         // 达到这个命中次数才算热点数据
+	compressor        Compressor    // 压缩算法, 为nil时不压缩
+	compressThreshold int           // 不小于这个大小的数据才会被压缩
+	snapshotPath      string        // 快照文件路径, 为空时不使用快照
+	snapshotInterval  time.Duration // 定期快照的间隔
+
+	refreshMu  sync.Mutex
+	refreshing map[string]struct{} // 正在后台刷新的key
+
+	batcher *batcher // 开启批量加载时不为nil
+
+	listeners      []Listener
+	eventQueueSize int        // 事件队列的长度
+	events         chan Event // 有Listener时才会创建
 }
 
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
-	if getter == nil {
-		panic("getter is nil")
+// NewGroup 在DefaultRegistry中创建group, 同名的group已经存在时panic
+// 需要处理错误或者隔离状态时使用Registry.NewGroup
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...Option) *Group {
+	g, err := DefaultRegistry.NewGroup(name, cacheBytes, getter, opts...)
+	if err != nil {
+		panic(err)
 	}
+	return g
+}
 
-	mu.Lock()
-	defer mu.Unlock()
-
+func newGroup(name string, cacheBytes int64, getter Getter, opts ...Option) *Group {
 	g := &Group{
 		name:   name,
 		getter: getter,
 		mainCache: cache{
 			cacheBytes: cacheBytes,
 		},
-		loader: &singleflight.Group{},
+		self:           defaultOrigin,
+		loader:         &singleflight.Group{},
+		refreshing:     make(map[string]struct{}),
+		done:           make(chan struct{}),
+		eventQueueSize: defaultEventQueueSize,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if len(g.listeners) > 0 {
+		g.events = make(chan Event, g.eventQueueSize)
+		g.mainCache.notify = g.notifyEvict
+		go g.dispatchEvents()
 	}
 
-	groups[name] = g
+	// 冷启动时先从快照恢复, 减轻数据源的压力
+	if g.snapshotPath != "" {
+		if err := g.restoreFromFile(g.snapshotPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("restore group %s from %s failed: %v", name, g.snapshotPath, err)
+		}
+		if g.snapshotInterval > 0 {
+			go g.snapshotLoop(g.snapshotPath, g.snapshotInterval)
+		}
+	}
 	return g
 }
 
 // RegisterPeerPicker 相当于把PeerPicker的初始化从NewGroup中单独拉出来的
 // 主要是考虑到这个PeerPicker可能比较复杂, 而且NewGroup参数列表已经很长了
-// 这个函数每个Group只能调用一次
+// 这个函数每个Group只能调用一次, 运行时替换PeerPicker请使用SetPeerPicker
 func (g *Group) RegisterPeerPicker(picker PeerPicker) {
+	g.peersMu.Lock()
+	defer g.peersMu.Unlock()
+
 	if g.peers != nil {
 		panic("RegisterPeerPicker have been called before")
 	}
-	g.peers = picker
+	g.setPeerPicker(picker)
 }
 
-// GetGroup 对应group不存在时返回nil
+// GetGroup 在DefaultRegistry中查找group, 不存在时返回nil
 func GetGroup(name string) *Group {
-	mu.RLock()
-	defer mu.RUnlock()
+	return DefaultRegistry.GetGroup(name)
+}
 
-	g, ok := groups[name]
-	if !ok {
-		return nil
-	}
-	return g
+// GroupNames DefaultRegistry中所有group的名字, 按字典序排列
+func GroupNames() []string {
+	return DefaultRegistry.GroupNames()
 }
 
-// Get simpleCache对外服务的唯一接口
+// Get simpleCache对外服务的主要接口
 // 若本地缓存命中,则从本地缓存中获取数据 -> getLocally
 // 本地缓存未命中,且对应key不由本地缓存负责时,请求对应的远程缓存来获取数据 -> getRemote
 // 本地缓存未命中,且对应key由本地缓存负责时,调用用户传入的回调函数从数据源获取数据 -> g.getter.Get
@@ -84,63 +142,200 @@ func (g *Group) Get(key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, errors.New("get a empty key")
 	}
+	if g.isClosed() {
+		return ByteView{}, ErrGroupClosed
+	}
 
-	data, ok := g.mainCache.get(key)
-	if ok {
+	if data, ok := g.lookupCache(key); ok {
 		return data, nil
 	}
 	return g.load(key)
 }
 
+// lookupCache 查询本地缓存, 记录统计信息和命中事件, Get和GetReader共用
+func (g *Group) lookupCache(key string) (ByteView, bool) {
+	atomic.AddInt64(&g.stats.gets, 1)
+	data, ok := g.mainCache.get(key)
+	if !ok {
+		g.emit(Event{Type: EventMiss, Key: key})
+		return ByteView{}, false
+	}
+	atomic.AddInt64(&g.stats.hits, 1)
+	g.emit(Event{Type: EventHit, Key: key, Value: data})
+	// 数据已经不够新鲜时先返回旧数据, 再在后台重新加载
+	if g.needRefresh(data, time.Now()) {
+		g.refreshAsync(key)
+	}
+	return data, true
+}
+
 // 缓存未命中时的处理
 func (g *Group) load(key string) (ByteView, error) {
 	// 将有可能调用回调函数从数据源载入数据的过程都用singlefilght保护起来
-	data, err := g.loader.Do(key, func() (any, error) {
-		peerGetter, ok := g.peers.PickPeer(key)
-		if ok {
-			// 请求远端缓存获取数据
-			data, err := g.getFromPeer(peerGetter, key)
-			if err == nil {
-				return data, nil
-			}
-			// 失败了就打日志+本地执行回调
-			log.Printf("get data(key:%s) from peer failed: %v", key, err)
-		}
-
-		// 本地调用回调获取数据
-		return g.getLocally(key)
+	data, err, _ := g.loader.Do(key, func() (any, error) {
+		atomic.AddInt64(&g.stats.loads, 1)
+		g.emit(Event{Type: EventLoadStart, Key: key})
+		start := time.Now()
+		data, err := g.fetch(key)
+		g.emit(Event{Type: EventLoadDone, Key: key, Value: data, Err: err, Duration: time.Since(start)})
+		return data, err
 	})
 
 	if err != nil {
-		return ByteView{}, err
+		return g.serveStale(key, err)
 	}
 	return data.(ByteView), nil
 }
 
+// serveStale 数据源出错时用宽限区中的旧数据兜底
+func (g *Group) serveStale(key string, err error) (ByteView, error) {
+	if stale, ok := g.mainCache.getStale(key); ok {
+		atomic.AddInt64(&g.stats.staleServed, 1)
+		log.Printf("load key %s of group %s failed, serve stale data: %v", key, g.name, err)
+		return stale, nil
+	}
+	return ByteView{}, err
+}
+
+// fetch 优先从负责key的peer获取数据, 失败或由本地负责时调用回调函数
+func (g *Group) fetch(key string) (ByteView, error) {
+	peers := g.peerPicker()
+	if peers == nil {
+		return g.getLocally(key)
+	}
+
+	peerGetter, ok := peers.PickPeer(key)
+	if ok {
+		// 请求远端缓存获取数据
+		data, err := g.getFromPeer(peerGetter, key)
+		if err == nil {
+			return data, nil
+		}
+		// 失败了就打日志+本地执行回调
+		log.Printf("get data(key:%s) from peer failed: %v", key, err)
+	}
+
+	// 本地调用回调获取数据
+	return g.getLocally(key)
+}
+
 // 本地调用回调函数从数据源获取数据
 func (g *Group) getLocally(key string) (ByteView, error) {
-	data, err := g.getter.Get(key)
+	atomic.AddInt64(&g.stats.localLoads, 1)
+	data, err := g.getFromSource(key)
 	if err != nil {
+		atomic.AddInt64(&g.stats.localErrors, 1)
 		return ByteView{}, err
 	}
 
-	value := ByteView{b: data}
+	value := g.newView(data, g.ttl)
 	g.populateCache(key, value)
 	return value, nil
 }
 
+// getFromSource 调用回调函数, 开启了批量加载并且回调函数支持时和其它key合并成一次调用
+func (g *Group) getFromSource(key string) ([]byte, error) {
+	getter := g.currentGetter()
+	if g.batcher != nil {
+		if bg, ok := getter.(BatchGetter); ok {
+			return g.batcher.get(bg, key)
+		}
+	}
+	return getter.Get(key)
+}
+
+// newView 为新载入或写入的数据生成ByteView, 填充元数据并按需压缩
+func (g *Group) newView(data []byte, ttl time.Duration) ByteView {
+	value := ByteView{
+		b:       data,
+		version: versionOf(data),
+		ctime:   time.Now(),
+		origin:  g.origin(),
+		hits:    new(int64),
+	}
+	if ttl > 0 {
+		value.expire = value.ctime.Add(ttl)
+	}
+	return g.compress(value)
+}
+
 // 从peer获取数据
 func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 	req := &pb.Request{
-		Group: g.name,
-		Key:   key,
+		Group:           g.name,
+		Key:             key,
+		AcceptEncodings: compressorNames(),
 	}
 	resp := &pb.Response{}
+	atomic.AddInt64(&g.stats.peerLoads, 1)
+	start := time.Now()
 	err := peer.GetDataFromPeer(req, resp)
+	if err != nil {
+		atomic.AddInt64(&g.stats.peerErrors, 1)
+	}
+	view, err := decodePeerResponse(resp, err)
+	g.emit(Event{Type: EventPeerFetch, Key: key, Value: view, Err: err, Duration: time.Since(start)})
+	return view, err
+}
+
+// decodePeerResponse 把peer返回的数据转换成ByteView, err是请求peer的结果
+func decodePeerResponse(resp *pb.Response, err error) (ByteView, error) {
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{b: resp.Value}, nil
+	if resp.Encoding != "" {
+		if _, ok := getCompressor(resp.Encoding); !ok {
+			return ByteView{}, fmt.Errorf("peer returned unknown encoding %s", resp.Encoding)
+		}
+	}
+	return ByteView{
+		b:        resp.Value,
+		encoding: resp.Encoding,
+		version:  resp.Version,
+		ctime:    fromUnixNano(resp.Ctime),
+		expire:   fromUnixNano(resp.Expire),
+		origin:   resp.Origin,
+		ctype:    resp.ContentType,
+	}, nil
+}
+
+// Set 直接向本地缓存写入数据, 不经过数据源
+// 数据只写入本节点, 调用方应当把写请求发给负责这个key的节点
+func (g *Group) Set(key string, value []byte, opts ...SetOption) error {
+	if key == "" {
+		return errors.New("set a empty key")
+	}
+	if g.isClosed() {
+		return ErrGroupClosed
+	}
+
+	o := setOptions{ttl: g.ttl}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// value由调用方持有, 需要拷贝一份保证只读
+	data := make([]byte, len(value))
+	copy(data, value)
+	view := g.newView(data, o.ttl)
+	view.ctype = o.contentType
+	g.populateCache(key, view)
+	return nil
+}
+
+// Touch 把本地缓存中数据的存活时间重置为ttl, 0表示永不过期
+// 返回本地是否存在这条数据
+func (g *Group) Touch(key string, ttl time.Duration) bool {
+	var expire time.Time
+	if ttl > 0 {
+		expire = time.Now().Add(ttl)
+	}
+	return g.mainCache.touch(key, expire)
+}
+
+// Remove 从本地缓存的所有层级中删除数据, 返回本地是否存在这条数据
+func (g *Group) Remove(key string) bool {
+	return g.mainCache.remove(key)
 }
 
 // 向group的缓存中添加数据
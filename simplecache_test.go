@@ -3,9 +3,13 @@ package simpleCache
 // copy了一些别人的简单测试
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"simpleCache/singleflight"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -14,9 +18,20 @@ var db = map[string]string{
 	"Sam":  "567",
 }
 
+// newTestGroup 在reg中创建group, 每个测试使用自己的Registry, 互不影响
+func newTestGroup(t testing.TB, reg *Registry, name string, cacheBytes int64, getter Getter, opts ...Option) *Group {
+	t.Helper()
+	g, err := reg.NewGroup(name, cacheBytes, getter, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
 func TestGet(t *testing.T) {
+	reg := NewRegistry()
 	loadCounts := make(map[string]int, len(db))
-	sim := NewGroup("scores", 2<<10, GetterFunc(
+	sim := newTestGroup(t, reg, "scores", 2<<10, GetterFunc(
 		func(key string) ([]byte, error) {
 			log.Println("[SlowDB] search key", key)
 			if v, ok := db[key]; ok {
@@ -42,3 +57,152 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+func TestDiskTier(t *testing.T) {
+	reg := NewRegistry()
+	loads := 0
+	sim := newTestGroup(t, reg, "disk-tier", 10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte("value-" + key), nil
+		}), WithDiskTier(t.TempDir(), 0))
+	defer sim.mainCache.disk.Close()
+
+	// 内存只能放下一条数据, k1会被挤到磁盘上
+	for _, k := range []string{"k1", "k2", "k1"} {
+		if view, err := sim.Get(k); err != nil || view.String() != "value-"+k {
+			t.Fatalf("failed to get %s", k)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("evicted key should be served from disk, loads %d", loads)
+	}
+}
+
+func TestSoftTTL(t *testing.T) {
+	reg := NewRegistry()
+	var loads int64
+	sim := newTestGroup(t, reg, "soft-ttl", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			n := atomic.AddInt64(&loads, 1)
+			return []byte(fmt.Sprintf("%s-%d", key, n)), nil
+		}), WithTTL(time.Hour), WithSoftTTL(20*time.Millisecond))
+
+	if view, _ := sim.Get("k"); view.String() != "k-1" {
+		t.Fatalf("first load got %s", view)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// 软过期后直接返回旧数据, 并在后台刷新
+	if view, _ := sim.Get("k"); view.String() != "k-1" {
+		t.Fatalf("stale value should be served, got %s", view)
+	}
+	waitFor(t, func() bool {
+		view, _ := sim.Get("k")
+		return view.String() == "k-2"
+	})
+}
+
+func TestRefreshAhead(t *testing.T) {
+	reg := NewRegistry()
+	var loads int64
+	sim := newTestGroup(t, reg, "refresh-ahead", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			atomic.AddInt64(&loads, 1)
+			return []byte(key), nil
+		}), WithTTL(100*time.Millisecond), WithRefreshAhead(80*time.Millisecond, 3))
+
+	_, _ = sim.Get("hot")
+	_, _ = sim.Get("cold")
+	time.Sleep(40 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, _ = sim.Get("hot")
+	}
+	_, _ = sim.Get("cold")
+
+	waitFor(t, func() bool { return atomic.LoadInt64(&loads) == 3 })
+	time.Sleep(70 * time.Millisecond)
+	if _, ok := sim.mainCache.get("hot"); !ok {
+		t.Fatal("hot key should have been refreshed before expiry")
+	}
+	if _, ok := sim.mainCache.get("cold"); ok {
+		t.Fatal("cold key should not be refreshed")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
+func TestStaleIfError(t *testing.T) {
+	reg := NewRegistry()
+	var down int32
+	sim := newTestGroup(t, reg, "stale-if-error", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if atomic.LoadInt32(&down) == 1 {
+				return nil, fmt.Errorf("database is down")
+			}
+			return []byte(key), nil
+		}), WithTTL(10*time.Millisecond), WithStaleIfError(50*time.Millisecond))
+
+	if view, err := sim.Get("k"); err != nil || view.Stale() {
+		t.Fatal("fresh value should not be stale")
+	}
+	atomic.StoreInt32(&down, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	view, err := sim.Get("k")
+	if err != nil || !view.Stale() || view.String() != "k" {
+		t.Fatalf("expired value should be served as stale, got %v %v", view, err)
+	}
+
+	// 超过最大陈旧时间后不再兜底
+	time.Sleep(50 * time.Millisecond)
+	if _, err = sim.Get("k"); err == nil {
+		t.Fatal("value older than max staleness should not be served")
+	}
+}
+
+func TestGetterPanic(t *testing.T) {
+	reg := NewRegistry()
+	sim := newTestGroup(t, reg, "getter-panic", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			panic("getter is broken")
+		}))
+
+	// Getter的panic变成错误返回, 不会让合并等待的请求卡住
+	var pe *singleflight.PanicError
+	if _, err := sim.Get("k"); !errors.As(err, &pe) {
+		t.Fatalf("get got %v", err)
+	}
+}
+
+func TestDiskViewFormat(t *testing.T) {
+	view := ByteView{
+		b:        []byte("value"),
+		encoding: "gzip",
+		version:  "v1",
+		ctime:    time.Unix(0, time.Now().UnixNano()),
+		origin:   "node1",
+		ctype:    "text/plain",
+	}
+	got, ok := decodeDiskView(encodeDiskView(view), time.Time{})
+	if !ok || string(got.b) != "value" || got.encoding != view.encoding || got.version != view.version ||
+		!got.ctime.Equal(view.ctime) || got.origin != view.origin || got.ctype != view.ctype {
+		t.Fatalf("decoded %+v, want %+v", got, view)
+	}
+
+	// 其它格式的数据无法读取
+	data := encodeDiskView(view)
+	data[0] = diskViewFormat + 1
+	if _, ok = decodeDiskView(data, time.Time{}); ok {
+		t.Fatal("unknown disk format should be rejected")
+	}
+}
@@ -1,6 +1,35 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// errGoexit fn调用了runtime.Goexit, 等待的调用方收到这个错误
+var errGoexit = errors.New("singleflight: fn called runtime.Goexit")
+
+// PanicError fn发生panic时, 所有等待结果的调用方都会收到这个错误
+// Error只包含panic的值, 错误信息会返回给客户端, 调用栈只能通过Stack获取
+type PanicError struct {
+	Value any    // recover得到的值
+	Stack []byte // 发生panic时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	// 错误信息保持在一行, 避免破坏RESP等基于行的协议
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(fmt.Sprintf("fn panicked: %v", e.Value))
+}
+
+// Result DoChan返回的结果, Shared表示结果是否被多个调用方共享
+type Result struct {
+	Val    any
+	Err    error
+	Shared bool
+}
 
 // Group 将同样的缓存请求合并成一个
 type Group struct {
@@ -12,9 +41,15 @@ type caller struct {
 	wg  sync.WaitGroup
 	val any
 	err error
+
+	dups   int             // 合并进来的调用方个数
+	shared bool            // 结束时dups是否大于0
+	chans  []chan<- Result // DoChan的调用方
 }
 
-func (g *Group) Do(id string, fn func() (any, error)) (any, error) {
+// Do 执行fn并返回结果, 同一个id同时只会执行一次, 重复的调用等待并共享第一次调用的结果
+// fn发生panic时所有调用方都会得到*PanicError
+func (g *Group) Do(id string, fn func() (any, error)) (v any, err error, shared bool) {
 	g.mu.Lock()
 
 	// 延迟初始化
@@ -24,9 +59,10 @@ func (g *Group) Do(id string, fn func() (any, error)) (any, error) {
 
 	c, ok := g.callers[id]
 	if ok {
+		c.dups++
 		g.mu.Unlock() // 这里解锁可以让后续的重复查询也进入到下一行阻塞等结果
 		c.wg.Wait()
-		return c.val, c.err
+		return c.val, c.err, true
 	}
 
 	// 首个查询才会真正执行
@@ -35,13 +71,84 @@ func (g *Group) Do(id string, fn func() (any, error)) (any, error) {
 	g.callers[id] = c
 	g.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done()
+	g.doCall(c, id, fn)
+	return c.val, c.err, c.shared
+}
 
-	// 删除标记
+// DoChan 和Do相同, 但不阻塞, 结果从返回的channel中读取, 可以配合select实现超时
+// 首个调用的fn在新的goroutine中执行, 调用方放弃等待不会影响fn的执行
+func (g *Group) DoChan(id string, fn func() (any, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.callers == nil {
+		g.callers = make(map[string]*caller)
+	}
+
+	if c, ok := g.callers[id]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+
+	c := &caller{chans: []chan<- Result{ch}}
+	c.wg.Add(1)
+	g.callers[id] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, id, fn)
+	return ch
+}
+
+// DoContext 和Do相同, 但ctx结束时立即返回ctx.Err()
+// 只有当前调用方放弃等待, fn会继续执行, 其它调用方仍然可以得到结果
+func (g *Group) DoContext(ctx context.Context, id string, fn func() (any, error)) (v any, err error, shared bool) {
+	select {
+	case r := <-g.DoChan(id, fn):
+		return r.Val, r.Err, r.Shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), false
+	}
+}
+
+// Forget 忘记正在进行的调用, 之后对id的调用会重新执行fn, 而不是等待这次调用的结果
+// 已经在等待的调用方仍然会得到这次调用的结果
+func (g *Group) Forget(id string) {
 	g.mu.Lock()
 	delete(g.callers, id)
 	g.mu.Unlock()
+}
+
+// doCall 执行fn并通知所有等待的调用方, fn发生panic或调用runtime.Goexit时也会通知
+func (g *Group) doCall(c *caller, id string, fn func() (any, error)) {
+	returned := false
+	defer func() {
+		if !returned {
+			c.err = errGoexit
+		}
+
+		g.mu.Lock()
+		// Forget之后同一个id可能已经开始了新的调用, 不能删掉它
+		if g.callers[id] == c {
+			delete(g.callers, id)
+		}
+		c.shared = c.dups > 0
+		chans := c.chans
+		g.mu.Unlock()
+
+		c.wg.Done()
+		for _, ch := range chans {
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.shared}
+		}
+	}()
 
-	return c.val, c.err
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.val, c.err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		c.val, c.err = fn()
+	}()
+	returned = true
 }
@@ -0,0 +1,157 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do("key", func() (any, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("Do got %v, %v, %v", v, err, shared)
+	}
+}
+
+func TestDoDupSuppress(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var sharedCount int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.Do("key", fn)
+			if v != "bar" || err != nil {
+				t.Errorf("Do got %v, %v", v, err)
+			}
+			if shared {
+				atomic.AddInt32(&sharedCount, 1)
+			}
+		}()
+	}
+	// 等所有调用方都进入Do之后再返回结果
+	for {
+		g.mu.Lock()
+		c := g.callers["key"]
+		joined := c != nil && c.dups == n-1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 || sharedCount != n {
+		t.Fatalf("fn called %d times, %d results shared", calls, sharedCount)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch := g.DoChan("key", func() (any, error) {
+		<-release
+		panic("boom")
+	})
+
+	waiter := make(chan error)
+	go func() {
+		_, err, _ := g.Do("key", nil)
+		waiter <- err
+	}()
+	for {
+		g.mu.Lock()
+		joined := g.callers["key"].dups == 1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	// panic会传递给所有调用方, 而不是让它们一直等待
+	var pe *PanicError
+	if r := <-ch; !errors.As(r.Err, &pe) || pe.Value != "boom" || !r.Shared {
+		t.Fatalf("DoChan got %v", r.Err)
+	}
+	if err := <-waiter; !errors.As(err, &pe) {
+		t.Fatalf("waiter got %v", err)
+	}
+	// 调用栈不能出现在错误信息中
+	if msg := pe.Error(); msg != "fn panicked: boom" || len(pe.Stack) == 0 {
+		t.Fatalf("panic error is %q", msg)
+	}
+	if _, err, _ := g.Do("key", func() (any, error) { return 1, nil }); err != nil {
+		t.Fatal("key should be usable after a panic")
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	first := g.DoChan("key", func() (any, error) {
+		<-release
+		return 1, nil
+	})
+
+	g.Forget("key")
+	if v, _, _ := g.Do("key", func() (any, error) { return 2, nil }); v != 2 {
+		t.Fatalf("forgotten key should be called again, got %v", v)
+	}
+
+	third := g.DoChan("key", func() (any, error) {
+		return 3, nil
+	})
+	close(release)
+	if r := <-first; r.Val != 1 {
+		t.Fatalf("first call got %v", r.Val)
+	}
+	if r := <-third; r.Val != 3 {
+		t.Fatalf("third call got %v", r.Val)
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (any, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	// 第一个调用方超时放弃, 不影响正在执行的fn和其它调用方
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.DoContext(ctx, "key", fn); err != context.DeadlineExceeded {
+		t.Fatalf("DoContext got %v", err)
+	}
+	ch := g.DoChan("key", fn)
+	close(release)
+	if r := <-ch; r.Val != "bar" || r.Err != nil || !r.Shared {
+		t.Fatalf("DoChan got %v", r)
+	}
+	if calls != 1 {
+		t.Fatalf("fn called %d times", calls)
+	}
+}
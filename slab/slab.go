@@ -0,0 +1,393 @@
+package slab
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+/* 参考bigcache和freecache的存储引擎
+ * 所有数据顺序写入一块预先分配的环形缓冲区, 索引是map[uint64]uint32, 两者都不包含指针,
+ * 缓存千万级别的小数据时GC不需要逐条扫描
+ * 索引的key是数据key的64位哈希值, 每条数据中保存了完整的key, 读取时比较key来识别哈希冲突
+ * 空间不足时从最旧的数据开始淘汰, 最近被访问过的数据会被挪到队头再给一次机会(CLOCK算法), 近似LRU
+ * 和lru.Cache一样不是并发安全的, 由调用方加锁
+ */
+
+// 每条数据的格式: hash(8) | atime(8) | keyLen(2) | valLen(4) | flags(1) | 保留(1) | key | value
+// 数据可以跨过缓冲区的末尾, 从头部继续
+const (
+	headerSize  = 24
+	flagsOffset = 22
+
+	flagDeleted  = 1 << 0 // 已经被删除或覆盖, 等待回收
+	flagAccessed = 1 << 1 // 上次经过队尾之后被访问过
+
+	maxKeyLen = 1<<16 - 1
+
+	// 一次腾出空间时最多把多少条数据挪回队头, 避免数据都被访问过时反复搬运
+	maxReinserts = 8
+)
+
+// MaxCapacity 偏移量用uint32保存, 缓冲区最大4GB, 32位平台上还受int范围的限制
+const MaxCapacity uint64 = math.MaxUint32
+
+var ErrTooLarge = errors.New("slab: entry is too large")
+
+type Cache struct {
+	buf   []byte
+	head  int // 下一条数据写入的位置
+	tail  int // 最旧的数据的位置
+	used  int // 环中已占用的字节数, 包括已删除但还没被回收的数据
+	index map[uint64]uint32
+
+	count int   // 有效数据的条数
+	live  int64 // 有效数据占用的字节数, 包括每条数据的头部
+
+	scratch []byte // 搬运数据时使用的缓冲区
+
+	// 一个钩子函数, 数据因为空间不足或哈希冲突被淘汰时调用, key和value都是拷贝
+	OnEvict func(key string, value []byte)
+
+	// Clock 不为nil时记录每条数据最近一次被访问的时间
+	Clock func() int64
+}
+
+// New capacity为缓冲区的大小, 会被一次性分配, 不能超过MaxCapacity
+func New(capacity int) *Cache {
+	if capacity <= 0 || uint64(capacity) > MaxCapacity {
+		panic("slab: invalid capacity")
+	}
+	return &Cache{
+		buf:   make([]byte, capacity),
+		index: make(map[uint64]uint32),
+	}
+}
+
+type header struct {
+	hash   uint64
+	atime  int64
+	keyLen uint16
+	valLen uint32
+	flags  byte
+}
+
+func (h *header) size() int {
+	return headerSize + int(h.keyLen) + int(h.valLen)
+}
+
+// Get 返回value的拷贝
+func (c *Cache) Get(key string) ([]byte, bool) {
+	off, h, ok := c.find(key)
+	if !ok {
+		return nil, false
+	}
+	c.setFlags(off, h.flags|flagAccessed)
+	if c.Clock != nil {
+		c.setAtime(off, c.Clock())
+	}
+
+	value := make([]byte, h.valLen)
+	c.read(c.advance(off, headerSize+int(h.keyLen)), value)
+	return value, true
+}
+
+// Peek 返回value的拷贝, 不设置访问标记和访问时间
+func (c *Cache) Peek(key string) ([]byte, bool) {
+	off, h, ok := c.find(key)
+	if !ok {
+		return nil, false
+	}
+	value := make([]byte, h.valLen)
+	c.read(c.advance(off, headerSize+int(h.keyLen)), value)
+	return value, true
+}
+
+// Set 写入数据, 已经存在的数据会被覆盖
+// 数据加上头部超过缓冲区大小时返回ErrTooLarge
+func (c *Cache) Set(key string, value []byte) error {
+	size := headerSize + len(key) + len(value)
+	if len(key) > maxKeyLen || size > len(c.buf) {
+		return ErrTooLarge
+	}
+
+	hash := hashKey(key)
+	if off, ok := c.index[hash]; ok {
+		h := c.readHeader(int(off))
+		if c.keyEqual(int(off), &h, key) {
+			c.remove(int(off), &h, false)
+		} else {
+			// 哈希冲突, 旧数据被挤掉
+			c.remove(int(off), &h, true)
+		}
+	}
+	c.makeSpace(size)
+
+	var atime int64
+	if c.Clock != nil {
+		atime = c.Clock()
+	}
+	var hdr [headerSize]byte
+	binary.LittleEndian.PutUint64(hdr[0:], hash)
+	binary.LittleEndian.PutUint64(hdr[8:], uint64(atime))
+	binary.LittleEndian.PutUint16(hdr[16:], uint16(len(key)))
+	binary.LittleEndian.PutUint32(hdr[18:], uint32(len(value)))
+
+	off := c.head
+	c.write(off, hdr[:])
+	c.writeString(c.advance(off, headerSize), key)
+	c.write(c.advance(off, headerSize+len(key)), value)
+	c.index[hash] = uint32(off)
+	c.head = c.advance(off, size)
+	c.used += size
+	c.count++
+	c.live += int64(size)
+	return nil
+}
+
+// Delete 删除数据, 返回数据是否存在, 不会触发OnEvict
+func (c *Cache) Delete(key string) bool {
+	off, h, ok := c.find(key)
+	if !ok {
+		return false
+	}
+	c.remove(off, &h, false)
+	return true
+}
+
+// RemoveOldest 淘汰最旧的一条数据, 不考虑是否被访问过, 会触发OnEvict
+func (c *Cache) RemoveOldest() {
+	c.skipDeleted()
+	if c.used == 0 {
+		return
+	}
+	h := c.readHeader(c.tail)
+	c.remove(c.tail, &h, true)
+	c.skipDeleted()
+}
+
+// OldestAccess 最旧的一条数据最近一次被访问的时间, 需要设置Clock
+func (c *Cache) OldestAccess() (int64, bool) {
+	c.skipDeleted()
+	if c.used == 0 {
+		return 0, false
+	}
+	h := c.readHeader(c.tail)
+	return h.atime, true
+}
+
+// Range 从最旧到最新遍历数据, fn返回false时停止遍历, key和value都是拷贝
+// 遍历不会改变数据的位置和访问标记
+func (c *Cache) Range(fn func(key string, value []byte) bool) {
+	for off, n := c.tail, 0; n < c.used; {
+		h := c.readHeader(off)
+		if h.flags&flagDeleted == 0 {
+			key, value := c.readEntry(off, &h)
+			if !fn(key, value) {
+				return
+			}
+		}
+		off = c.advance(off, h.size())
+		n += h.size()
+	}
+}
+
+// Resize 重新分配大小为capacity的缓冲区, 按从旧到新的顺序搬运数据
+// 缩小时放不下的旧数据会被淘汰并触发OnEvict
+func (c *Cache) Resize(capacity int) {
+	if capacity == len(c.buf) {
+		return
+	}
+	n := New(capacity)
+	n.OnEvict, n.Clock = c.OnEvict, c.Clock
+	c.Range(func(key string, value []byte) bool {
+		if n.Set(key, value) == ErrTooLarge && c.OnEvict != nil {
+			c.OnEvict(key, value)
+		}
+		return true
+	})
+	*c = *n
+}
+
+// Purge 清空缓存, 从最旧到最新依次对每条数据触发OnEvict, 缓冲区会被保留
+func (c *Cache) Purge() {
+	if c.OnEvict != nil {
+		c.Range(func(key string, value []byte) bool {
+			c.OnEvict(key, value)
+			return true
+		})
+	}
+	c.head, c.tail, c.used = 0, 0, 0
+	c.index = make(map[uint64]uint32)
+	c.count, c.live = 0, 0
+}
+
+// Len 有效数据的条数
+func (c *Cache) Len() int {
+	return c.count
+}
+
+// Bytes 有效数据占用的字节数, 包括每条数据的头部
+func (c *Cache) Bytes() int64 {
+	return c.live
+}
+
+// Cap 缓冲区的大小
+func (c *Cache) Cap() int {
+	return len(c.buf)
+}
+
+// makeSpace 从队尾开始回收空间, 直到能够写入size字节
+func (c *Cache) makeSpace(size int) {
+	reinserts := 0
+	for len(c.buf)-c.used < size {
+		off := c.tail
+		h := c.readHeader(off)
+		sz := h.size()
+		switch {
+		case h.flags&flagDeleted != 0:
+			c.tail = c.advance(off, sz)
+			c.used -= sz
+		case h.flags&flagAccessed != 0 && reinserts < maxReinserts:
+			// 被访问过的数据挪到队头, 先整体读出来, 因为写入的位置可能和原位置重叠
+			reinserts++
+			if cap(c.scratch) < sz {
+				c.scratch = make([]byte, sz)
+			}
+			entry := c.scratch[:sz]
+			c.read(off, entry)
+			entry[flagsOffset] &^= flagAccessed
+			c.tail = c.advance(off, sz)
+			c.write(c.head, entry)
+			c.index[h.hash] = uint32(c.head)
+			c.head = c.advance(c.head, sz)
+		default:
+			c.remove(off, &h, true)
+			c.tail = c.advance(off, sz)
+			c.used -= sz
+		}
+	}
+}
+
+// remove 把off处的数据标记为删除, 空间等到经过队尾时回收
+func (c *Cache) remove(off int, h *header, evict bool) {
+	if evict && c.OnEvict != nil {
+		key, value := c.readEntry(off, h)
+		c.OnEvict(key, value)
+	}
+	if idx, ok := c.index[h.hash]; ok && int(idx) == off {
+		delete(c.index, h.hash)
+	}
+	h.flags |= flagDeleted
+	c.setFlags(off, h.flags)
+	c.count--
+	c.live -= int64(h.size())
+}
+
+// skipDeleted 回收队尾已经删除的数据
+func (c *Cache) skipDeleted() {
+	for c.used > 0 {
+		h := c.readHeader(c.tail)
+		if h.flags&flagDeleted == 0 {
+			return
+		}
+		c.tail = c.advance(c.tail, h.size())
+		c.used -= h.size()
+	}
+}
+
+func (c *Cache) find(key string) (int, header, bool) {
+	off, ok := c.index[hashKey(key)]
+	if !ok {
+		return 0, header{}, false
+	}
+	h := c.readHeader(int(off))
+	if !c.keyEqual(int(off), &h, key) {
+		return 0, header{}, false
+	}
+	return int(off), h, true
+}
+
+func (c *Cache) readHeader(off int) header {
+	var b [headerSize]byte
+	c.read(off, b[:])
+	return header{
+		hash:   binary.LittleEndian.Uint64(b[0:]),
+		atime:  int64(binary.LittleEndian.Uint64(b[8:])),
+		keyLen: binary.LittleEndian.Uint16(b[16:]),
+		valLen: binary.LittleEndian.Uint32(b[18:]),
+		flags:  b[flagsOffset],
+	}
+}
+
+func (c *Cache) readEntry(off int, h *header) (string, []byte) {
+	key := make([]byte, h.keyLen)
+	c.read(c.advance(off, headerSize), key)
+	value := make([]byte, h.valLen)
+	c.read(c.advance(off, headerSize+int(h.keyLen)), value)
+	return string(key), value
+}
+
+func (c *Cache) setFlags(off int, flags byte) {
+	c.buf[c.advance(off, flagsOffset)] = flags
+}
+
+func (c *Cache) setAtime(off int, atime int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(atime))
+	c.write(c.advance(off, 8), b[:])
+}
+
+// keyEqual 不分配内存地比较off处数据的key
+func (c *Cache) keyEqual(off int, h *header, key string) bool {
+	if int(h.keyLen) != len(key) {
+		return false
+	}
+	start := c.advance(off, headerSize)
+	n := len(c.buf) - start
+	if n >= len(key) {
+		return string(c.buf[start:start+len(key)]) == key
+	}
+	return string(c.buf[start:]) == key[:n] && string(c.buf[:len(key)-n]) == key[n:]
+}
+
+func (c *Cache) advance(off int, n int) int {
+	off += n
+	if off >= len(c.buf) {
+		off -= len(c.buf)
+	}
+	return off
+}
+
+// read 从off开始读取len(p)字节, 到达末尾时从头部继续
+func (c *Cache) read(off int, p []byte) {
+	n := copy(p, c.buf[off:])
+	copy(p[n:], c.buf)
+}
+
+func (c *Cache) write(off int, p []byte) {
+	n := copy(c.buf[off:], p)
+	copy(c.buf, p[n:])
+}
+
+func (c *Cache) writeString(off int, s string) {
+	n := copy(c.buf[off:], s)
+	copy(c.buf, s[n:])
+}
+
+// hashKey 测试中替换成容易冲突的哈希函数
+var hashKey = fnv1a
+
+// fnv1a FNV-1a, 不分配内存
+func fnv1a(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
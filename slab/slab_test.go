@@ -0,0 +1,265 @@
+package slab
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"simpleCache/lru"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetSet(t *testing.T) {
+	c := New(1024)
+	if err := c.Set("k1", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Set("k2", []byte("v2"))
+	_ = c.Set("k1", []byte("value1"))
+	if v, ok := c.Get("k1"); !ok || string(v) != "value1" {
+		t.Fatalf("get k1 got %q", v)
+	}
+	if _, ok := c.Get("k3"); ok {
+		t.Fatal("k3 should miss")
+	}
+	if c.Len() != 2 || c.Bytes() != int64(2*headerSize+len("k1value1k2v2")) {
+		t.Fatalf("len %d, bytes %d", c.Len(), c.Bytes())
+	}
+	if !c.Delete("k2") || c.Delete("k2") || c.Len() != 1 {
+		t.Fatal("delete k2 failed")
+	}
+	if err := c.Set("big", make([]byte, 1024)); err != ErrTooLarge {
+		t.Fatalf("set a large value got %v", err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	var evicted []string
+	// 每条数据 24+2+2=28 字节, 能放下3条
+	c := New(90)
+	c.OnEvict = func(key string, value []byte) {
+		evicted = append(evicted, key+"="+string(value))
+	}
+	for i := 0; i < 4; i++ {
+		_ = c.Set(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)))
+	}
+	if !reflect.DeepEqual(evicted, []string{"k0=v0"}) {
+		t.Fatalf("evicted %v", evicted)
+	}
+
+	// 被访问过的k1得到第二次机会, k2被淘汰
+	c.Get("k1")
+	_ = c.Set("k4", []byte("v4"))
+	if _, ok := c.Get("k1"); !ok {
+		t.Fatal("recently used k1 should be kept")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatal("k2 should be evicted")
+	}
+
+	c.RemoveOldest()
+	if c.Len() != 2 || evicted[len(evicted)-1] != "k3=v3" {
+		t.Fatalf("RemoveOldest evicted %v", evicted)
+	}
+}
+
+func TestCollision(t *testing.T) {
+	hashKey = func(key string) uint64 { return 1 }
+	defer func() { hashKey = fnv1a }()
+
+	var evicted []string
+	c := New(1024)
+	c.OnEvict = func(key string, value []byte) {
+		evicted = append(evicted, key)
+	}
+	_ = c.Set("a", []byte("1"))
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("colliding key b should miss")
+	}
+	if c.Delete("b") {
+		t.Fatal("colliding key b should not be deleted")
+	}
+	_ = c.Set("b", []byte("2"))
+	if _, ok := c.Get("a"); ok || !reflect.DeepEqual(evicted, []string{"a"}) || c.Len() != 1 {
+		t.Fatalf("a should be evicted by colliding b, evicted %v", evicted)
+	}
+	if v, ok := c.Get("b"); !ok || string(v) != "2" {
+		t.Fatalf("get b got %q", v)
+	}
+}
+
+// TestRandom 随机读写, 和map比较结果, 覆盖数据跨过缓冲区末尾的情况
+func TestRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	c := New(4096)
+	model := make(map[string]string)
+	c.OnEvict = func(key string, value []byte) {
+		if model[key] != string(value) {
+			t.Fatalf("evicted %s=%s, model has %s", key, value, model[key])
+		}
+		delete(model, key)
+	}
+
+	for i := 0; i < 100000; i++ {
+		key := "key-" + strconv.Itoa(r.Intn(200))
+		switch r.Intn(10) {
+		case 0:
+			c.Delete(key)
+			delete(model, key)
+		case 1, 2, 3:
+			value := make([]byte, r.Intn(100))
+			r.Read(value)
+			if err := c.Set(key, value); err != nil {
+				t.Fatal(err)
+			}
+			model[key] = string(value)
+		default:
+			v, ok := c.Get(key)
+			want, exist := model[key]
+			if ok != exist || string(v) != want {
+				t.Fatalf("get %s got %v %v, want %v %v", key, v, ok, []byte(want), exist)
+			}
+		}
+		if c.Len() != len(model) {
+			t.Fatalf("len %d, model %d", c.Len(), len(model))
+		}
+	}
+
+	n := 0
+	c.Range(func(key string, value []byte) bool {
+		n++
+		if model[key] != string(value) {
+			t.Fatalf("range got %s=%x", key, value)
+		}
+		return true
+	})
+	if n != len(model) {
+		t.Fatalf("range visited %d entries, model has %d", n, len(model))
+	}
+
+	c.Resize(1024)
+	if c.Cap() != 1024 || c.Len() != len(model) || c.Bytes() > 1024 {
+		t.Fatalf("after resize len %d, model %d, bytes %d", c.Len(), len(model), c.Bytes())
+	}
+}
+
+func TestOldestAccess(t *testing.T) {
+	var now int64
+	c := New(1024)
+	c.Clock = func() int64 {
+		now++
+		return now
+	}
+	_ = c.Set("k1", []byte("v1"))
+	_ = c.Set("k2", []byte("v2"))
+	if atime, _ := c.OldestAccess(); atime != 1 {
+		t.Fatalf("oldest access is %d", atime)
+	}
+	c.Delete("k1")
+	if atime, _ := c.OldestAccess(); atime != 2 {
+		t.Fatalf("oldest access after delete is %d", atime)
+	}
+}
+
+// 下面的基准测试比较大量小数据时lru和slab对GC的影响
+// go test -bench GC -benchtime 20x ./slab
+
+const gcEntries = 2 << 20
+
+type bytesValue []byte
+
+func (b bytesValue) Len() int {
+	return len(b)
+}
+
+// measureGC 执行b.N次GC, 报告每次GC的耗时
+func measureGC(b *testing.B) {
+	runtime.GC()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N), "ns/gc")
+}
+
+func BenchmarkGCLRU(b *testing.B) {
+	c := lru.New(0, nil)
+	for i := 0; i < gcEntries; i++ {
+		c.Add("key-"+strconv.Itoa(i), bytesValue("value"))
+	}
+	measureGC(b)
+	runtime.KeepAlive(c)
+}
+
+func BenchmarkGCSlab(b *testing.B) {
+	c := New(gcEntries * (headerSize + 16))
+	for i := 0; i < gcEntries; i++ {
+		_ = c.Set("key-"+strconv.Itoa(i), []byte("value"))
+	}
+	measureGC(b)
+	runtime.KeepAlive(c)
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := New(64 << 20)
+	value := make([]byte, 100)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = c.Set("key-"+strconv.Itoa(i%100000), value)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := New(64 << 20)
+	value := make([]byte, 100)
+	for i := 0; i < 100000; i++ {
+		_ = c.Set("key-"+strconv.Itoa(i), value)
+	}
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(keys[i%len(keys)])
+	}
+}
+
+func TestPeekPurge(t *testing.T) {
+	var evicted []string
+	c := New(90)
+	c.OnEvict = func(key string, value []byte) {
+		evicted = append(evicted, key)
+	}
+	for i := 0; i < 3; i++ {
+		_ = c.Set(fmt.Sprintf("k%d", i), []byte(fmt.Sprintf("v%d", i)))
+	}
+
+	// Peek不设置访问标记, k0仍然最先被淘汰
+	if v, ok := c.Peek("k0"); !ok || string(v) != "v0" {
+		t.Fatalf("peek k0 got %q", v)
+	}
+	_ = c.Set("k3", []byte("v3"))
+	if !reflect.DeepEqual(evicted, []string{"k0"}) {
+		t.Fatalf("evicted %v", evicted)
+	}
+
+	c.Purge()
+	if !reflect.DeepEqual(evicted, []string{"k0", "k1", "k2", "k3"}) {
+		t.Fatalf("purge evicted %v", evicted)
+	}
+	if c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatalf("len %d, bytes %d after purge", c.Len(), c.Bytes())
+	}
+	if _, ok := c.Peek("k1"); ok {
+		t.Fatal("k1 should be purged")
+	}
+	_ = c.Set("k4", []byte("v4"))
+	if v, ok := c.Get("k4"); !ok || string(v) != "v4" {
+		t.Fatal("cache should be usable after purge")
+	}
+}
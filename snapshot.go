@@ -0,0 +1,326 @@
+package simpleCache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/* 快照格式(版本2):
+ * magic(4字节) | version(1字节)
+ * 若干条记录, 按LRU顺序从旧到新排列, 每条记录:
+ *   1 | keyLen(uvarint) | key | valLen(uvarint) | value | ctime(varint) | expire(varint) |
+ *   verLen(uvarint) | 数据版本 | originLen(uvarint) | origin | encLen(uvarint) | 压缩算法 | ctLen(uvarint) | Content-Type
+ * 结束标记:
+ *   0 | 记录条数(uvarint) | crc32(4字节, 覆盖之前的所有内容)
+ * 时间均为UnixNano, expire为0表示永不过期
+ */
+
+const (
+	snapshotMagic   = "SCSN"
+	snapshotVersion = 2
+
+	snapshotRecord = 1
+	snapshotEnd    = 0
+
+	snapshotMaxItem = 1 << 31 // 单个key或value的长度上限
+)
+
+var ErrSnapshotCorrupted = errors.New("snapshot corrupted")
+
+// Snapshot 把group中所有未过期的数据写入w
+// 恢复时会保持原来的LRU顺序
+func (g *Group) Snapshot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+
+	if _, err := out.Write([]byte{
+		snapshotMagic[0], snapshotMagic[1], snapshotMagic[2], snapshotMagic[3],
+		snapshotVersion,
+	}); err != nil {
+		return err
+	}
+
+	entries := g.mainCache.entries()
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, e := range entries {
+		if _, err := out.Write([]byte{snapshotRecord}); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, []byte(e.key)); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, e.value.b); err != nil {
+			return err
+		}
+		if err := writeVarint(out, buf, unixNano(e.value.ctime)); err != nil {
+			return err
+		}
+		if err := writeVarint(out, buf, unixNano(e.value.expire)); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, []byte(e.value.version)); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, []byte(e.value.origin)); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, []byte(e.value.encoding)); err != nil {
+			return err
+		}
+		if err := writeBytes(out, buf, []byte(e.value.ctype)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := out.Write([]byte{snapshotEnd}); err != nil {
+		return err
+	}
+	n := binary.PutUvarint(buf, uint64(len(entries)))
+	if _, err := out.Write(buf[:n]); err != nil {
+		return err
+	}
+
+	// crc本身不参与校验,只写入bw
+	binary.BigEndian.PutUint32(buf, crc.Sum32())
+	if _, err := bw.Write(buf[:4]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore 从r中读取Snapshot写出的快照并载入group
+// 快照会先完整读取并校验, 校验失败时不会修改group中的数据
+// 已经过期的数据会被跳过, 快照中的数据会覆盖group中同名的数据
+func (g *Group) Restore(r io.Reader) error {
+	crc := crc32.NewIEEE()
+	br := &snapshotReader{r: bufio.NewReader(r), crc: crc}
+
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("read snapshot header failed: %v", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrSnapshotCorrupted
+	}
+	version := header[len(snapshotMagic)]
+	if version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	var entries []cacheEntry
+	for {
+		flag, err := br.ReadByte()
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		if flag == snapshotEnd {
+			break
+		}
+		if flag != snapshotRecord {
+			return ErrSnapshotCorrupted
+		}
+
+		key, err := readBytes(br)
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		value, err := readBytes(br)
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		ctime, err := binary.ReadVarint(br)
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		expire, err := binary.ReadVarint(br)
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		view := ByteView{
+			b:      value,
+			ctime:  fromUnixNano(ctime),
+			expire: fromUnixNano(expire),
+			hits:   new(int64),
+		}
+		if err = readViewMeta(br, &view); err != nil {
+			return err
+		}
+		entries = append(entries, cacheEntry{key: string(key), value: view})
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil || count != uint64(len(entries)) {
+		return ErrSnapshotCorrupted
+	}
+	sum := crc.Sum32()
+	tail := make([]byte, 4)
+	if _, err = io.ReadFull(br.r, tail); err != nil || binary.BigEndian.Uint32(tail) != sum {
+		return ErrSnapshotCorrupted
+	}
+
+	// 按从旧到新的顺序放回缓存, 这样LRU顺序和快照时一致
+	now := time.Now()
+	for _, e := range entries {
+		if e.value.expired(now) {
+			continue
+		}
+		g.populateCache(e.key, e.value)
+	}
+	return nil
+}
+
+// readViewMeta 读取记录中expire之后的元数据: 数据版本、origin、压缩算法和Content-Type
+func readViewMeta(br *snapshotReader, view *ByteView) error {
+	var fields [4]string
+	for i := range fields {
+		b, err := readBytes(br)
+		if err != nil {
+			return ErrSnapshotCorrupted
+		}
+		fields[i] = string(b)
+	}
+	view.version, view.origin, view.encoding, view.ctype = fields[0], fields[1], fields[2], fields[3]
+	// 本节点没有注册的压缩算法无法解压, 读取时才会出错
+	if _, ok := getCompressor(view.encoding); view.encoding != "" && !ok {
+		return fmt.Errorf("snapshot contains unknown encoding %s", view.encoding)
+	}
+	return nil
+}
+
+// ErrNoSnapshotFile 没有通过WithSnapshotFile配置快照文件
+var ErrNoSnapshotFile = errors.New("snapshot file is not configured")
+
+// SaveSnapshot 立即把缓存内容写入WithSnapshotFile配置的快照文件
+func (g *Group) SaveSnapshot() error {
+	if g.snapshotPath == "" {
+		return ErrNoSnapshotFile
+	}
+	// 关闭后缓存已经清空, 不能用空快照覆盖之前的文件
+	if g.isClosed() {
+		return ErrGroupClosed
+	}
+	return g.snapshotToFile(g.snapshotPath)
+}
+
+// snapshotToFile 先写临时文件再rename, 避免进程中途退出留下不完整的快照
+func (g *Group) snapshotToFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = g.Snapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (g *Group) restoreFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// 定期把缓存内容写入快照文件
+func (g *Group) snapshotLoop(path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+			if err := g.snapshotToFile(path); err != nil {
+				log.Printf("snapshot group %s to %s failed: %v", g.name, path, err)
+			}
+		}
+	}
+}
+
+// snapshotReader 在读取的同时计算crc
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (s *snapshotReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	_, _ = s.crc.Write(p[:n])
+	return n, err
+}
+
+func (s *snapshotReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		_, _ = s.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func writeBytes(w io.Writer, buf []byte, data []byte) error {
+	n := binary.PutUvarint(buf, uint64(len(data)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func writeVarint(w io.Writer, buf []byte, v int64) error {
+	n := binary.PutVarint(buf, v)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+func readBytes(r *snapshotReader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	// 避免损坏的长度字段导致一次性申请过大的内存
+	if n > snapshotMaxItem {
+		return nil, ErrSnapshotCorrupted
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
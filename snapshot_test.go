@@ -0,0 +1,144 @@
+package simpleCache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSnapshotGroup(t *testing.T, reg *Registry, name string, opts ...Option) *Group {
+	return newTestGroup(t, reg, name, 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("v-" + key), nil
+		}), opts...)
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	reg := NewRegistry()
+	src := newSnapshotGroup(t, reg, "snapshot-src", WithTTL(time.Hour))
+	for _, k := range []string{"a", "b", "c"} {
+		if _, err := src.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a被访问后成为最新的数据
+	_, _ = src.Get("a")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestGroup(t, reg, "snapshot-dst", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Fatalf("restored key %s should not be loaded", key)
+			return nil, nil
+		}))
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, e := range dst.mainCache.entries() {
+		keys = append(keys, e.key)
+		if e.value.String() != "v-"+e.key {
+			t.Fatalf("restored value of %s is %s", e.key, e.value)
+		}
+		if e.value.expire.IsZero() || e.value.ctime.IsZero() {
+			t.Fatalf("ttl of %s is lost", e.key)
+		}
+		if e.value.version != versionOf(e.value.b) || e.value.origin != defaultOrigin {
+			t.Fatalf("metadata of %s is lost", e.key)
+		}
+		if e.value.hits == nil {
+			t.Fatalf("restored %s has no hit counter for refresh-ahead", e.key)
+		}
+	}
+	if want := []string{"b", "c", "a"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("lru order %v, want %v", keys, want)
+	}
+}
+
+func TestRestoreCorrupted(t *testing.T) {
+	reg := NewRegistry()
+	src := newSnapshotGroup(t, reg, "snapshot-corrupted-src")
+	_, _ = src.Get("a")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)/2] ^= 0xff
+
+	dst := newSnapshotGroup(t, reg, "snapshot-corrupted-dst")
+	if err := dst.Restore(bytes.NewReader(data)); err == nil {
+		t.Fatal("corrupted snapshot should be rejected")
+	}
+	if len(dst.mainCache.entries()) != 0 {
+		t.Fatal("corrupted snapshot should not modify the group")
+	}
+
+	// 只接受当前版本的快照
+	data[len(data)/2] ^= 0xff
+	data[len(snapshotMagic)] = snapshotVersion - 1
+	if err := dst.Restore(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("snapshot of other versions should be rejected, got %v", err)
+	}
+}
+
+// renamedCompressor 用其它名字注册的gzip
+type renamedCompressor struct {
+	GzipCompressor
+	name string
+}
+
+func (c renamedCompressor) Name() string {
+	return c.name
+}
+
+func TestRestoreUnknownEncoding(t *testing.T) {
+	c := renamedCompressor{GzipCompressor: GzipCompressor{Level: 6}, name: "snapshot-only"}
+	RegisterCompressor(c)
+	reg := NewRegistry()
+	src := newSnapshotGroup(t, reg, "snapshot-encoding-src", WithCompression(c, 1))
+	_, _ = src.Get(strings.Repeat("a", 200))
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	compressorsMu.Lock()
+	delete(compressors, c.name)
+	compressorsMu.Unlock()
+
+	dst := newSnapshotGroup(t, reg, "snapshot-encoding-dst")
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("snapshot with unknown encoding should be rejected")
+	}
+	if len(dst.mainCache.entries()) != 0 {
+		t.Fatal("rejected snapshot should not modify the group")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	reg := NewRegistry()
+	path := filepath.Join(t.TempDir(), "group.snap")
+	src := newSnapshotGroup(t, reg, "snapshot-file-src")
+	_, _ = src.Get("a")
+	if err := src.snapshotToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newSnapshotGroup(t, reg, "snapshot-file-dst", WithSnapshotFile(path, 0))
+	if v, ok := dst.mainCache.get("a"); !ok || v.String() != "v-a" {
+		t.Fatal("snapshot file is not loaded by NewGroup")
+	}
+}
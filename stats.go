@@ -0,0 +1,60 @@
+package simpleCache
+
+import "sync/atomic"
+
+// groupStats group运行时的统计信息, 全部使用原子操作更新
+type groupStats struct {
+	gets        int64 // Get的调用次数
+	hits        int64 // 本地缓存命中次数
+	loads       int64 // 未命中后实际执行加载的次数(经过singleflight合并)
+	localLoads  int64 // 调用Getter的次数
+	localErrors int64 // Getter返回错误的次数
+	batchLoads  int64 // 调用BatchGetter.GetBatch的次数
+	peerLoads   int64 // 请求peer的次数
+	peerErrors  int64 // 请求peer失败的次数
+	staleServed int64 // 加载失败后返回陈旧数据的次数
+
+	eventsDropped int64 // 事件队列满时丢弃的事件数
+}
+
+// Stats group统计信息的快照
+type Stats struct {
+	Name          string `json:"name"`
+	Gets          int64  `json:"gets"`
+	Hits          int64  `json:"hits"`
+	Loads         int64  `json:"loads"`
+	LocalLoads    int64  `json:"local_loads"`
+	LocalErrors   int64  `json:"local_errors"`
+	BatchLoads    int64  `json:"batch_loads"`
+	PeerLoads     int64  `json:"peer_loads"`
+	PeerErrors    int64  `json:"peer_errors"`
+	StaleServed   int64  `json:"stale_served"`
+	EventsDropped int64  `json:"events_dropped"`
+	Items         int    `json:"items"` // 内存中的数据条数
+	Bytes         int64  `json:"bytes"` // 内存中的数据占用的字节数
+}
+
+// Name group的名字
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Stats 返回group当前的统计信息
+func (g *Group) Stats() Stats {
+	items, bytes := g.mainCache.stats()
+	return Stats{
+		Name:          g.name,
+		Gets:          atomic.LoadInt64(&g.stats.gets),
+		Hits:          atomic.LoadInt64(&g.stats.hits),
+		Loads:         atomic.LoadInt64(&g.stats.loads),
+		LocalLoads:    atomic.LoadInt64(&g.stats.localLoads),
+		LocalErrors:   atomic.LoadInt64(&g.stats.localErrors),
+		BatchLoads:    atomic.LoadInt64(&g.stats.batchLoads),
+		PeerLoads:     atomic.LoadInt64(&g.stats.peerLoads),
+		PeerErrors:    atomic.LoadInt64(&g.stats.peerErrors),
+		StaleServed:   atomic.LoadInt64(&g.stats.staleServed),
+		EventsDropped: atomic.LoadInt64(&g.stats.eventsDropped),
+		Items:         items,
+		Bytes:         bytes,
+	}
+}
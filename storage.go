@@ -0,0 +1,171 @@
+package simpleCache
+
+import (
+	"encoding/binary"
+	"math"
+	"simpleCache/lru"
+	"simpleCache/slab"
+)
+
+// storage cache在内存中保存数据的存储引擎, 调用时需要持有cache.mu
+// 默认使用lru, 数据量很大时可以通过WithSlabStorage换成对GC更友好的slab
+type storage interface {
+	Get(key string) (ByteView, bool)
+	Peek(key string) (ByteView, bool) // 不改变淘汰顺序
+	Add(key string, value ByteView)
+	Remove(key string)
+	RemoveOldest()
+	Purge() // 清空数据, 每条数据都会触发OnEvict
+	Resize(maxBytes int64)
+	OldestAccess() (int64, bool)
+	Range(fn func(key string, value ByteView) bool) // 从最旧到最新遍历
+	Len() int
+	Bytes() int64
+}
+
+// sharedReader 可以在cache.mu的读锁下并发读取的存储引擎
+type sharedReader interface {
+	GetShared(key string) (ByteView, bool)
+}
+
+// lruStorage 把ByteView直接保存在lru中
+type lruStorage struct {
+	*lru.Cache
+}
+
+func newLRUStorage(maxBytes int64, overhead int64, clock func() int64, onEvict func(key string, value ByteView)) lruStorage {
+	var evict func(key string, val lru.Value)
+	if onEvict != nil {
+		evict = func(key string, val lru.Value) {
+			onEvict(key, val.(ByteView))
+		}
+	}
+	c := lru.New(maxBytes, evict)
+	c.Overhead = overhead
+	c.Clock = clock
+	return lruStorage{c}
+}
+
+func (s lruStorage) Get(key string) (ByteView, bool) {
+	val, ok := s.Cache.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return val.(ByteView), true
+}
+
+func (s lruStorage) Peek(key string) (ByteView, bool) {
+	val, ok := s.Cache.Peek(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return val.(ByteView), true
+}
+
+func (s lruStorage) GetShared(key string) (ByteView, bool) {
+	val, ok := s.Cache.GetShared(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return val.(ByteView), true
+}
+
+func (s lruStorage) Add(key string, value ByteView) {
+	s.Cache.Add(key, value)
+}
+
+func (s lruStorage) Range(fn func(key string, value ByteView) bool) {
+	s.Cache.Range(func(key string, val lru.Value) bool {
+		return fn(key, val.(ByteView))
+	})
+}
+
+// slabStorage 把ByteView编码后保存在slab的环形缓冲区中
+// 每次读取都会解码出新的ByteView, 所以不记录命中次数, refresh-ahead不会生效
+type slabStorage struct {
+	*slab.Cache
+}
+
+func newSlabStorage(capacity int64, clock func() int64, onEvict func(key string, value ByteView)) slabStorage {
+	c := slab.New(slabCapacity(capacity))
+	c.Clock = clock
+	if onEvict != nil {
+		c.OnEvict = func(key string, data []byte) {
+			if view, ok := decodeSlabView(data); ok {
+				onEvict(key, view)
+			}
+		}
+	}
+	return slabStorage{c}
+}
+
+func (s slabStorage) Get(key string) (ByteView, bool) {
+	data, ok := s.Cache.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return decodeSlabView(data)
+}
+
+func (s slabStorage) Peek(key string) (ByteView, bool) {
+	data, ok := s.Cache.Peek(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return decodeSlabView(data)
+}
+
+// Add 数据超过缓冲区大小时不缓存, 和lru拒绝特大数据的行为一致
+func (s slabStorage) Add(key string, value ByteView) {
+	_ = s.Cache.Set(key, encodeSlabView(value))
+}
+
+func (s slabStorage) Remove(key string) {
+	s.Cache.Delete(key)
+}
+
+// Resize slab的缓冲区必须有固定的大小, 0会被忽略
+func (s slabStorage) Resize(maxBytes int64) {
+	if maxBytes <= 0 {
+		return
+	}
+	s.Cache.Resize(slabCapacity(maxBytes))
+}
+
+// slabCapacity 把缓冲区大小限制在slab.MaxCapacity和int的范围内
+func slabCapacity(n int64) int {
+	if n > int64(slab.MaxCapacity) {
+		n = int64(slab.MaxCapacity)
+	}
+	if n > math.MaxInt {
+		n = math.MaxInt
+	}
+	return int(n)
+}
+
+func (s slabStorage) Range(fn func(key string, value ByteView) bool) {
+	s.Cache.Range(func(key string, data []byte) bool {
+		view, ok := decodeSlabView(data)
+		if !ok {
+			return true
+		}
+		return fn(key, view)
+	})
+}
+
+// slab中的数据格式: expire(varint) | 磁盘缓存的格式
+func encodeSlabView(v ByteView) []byte {
+	data := encodeDiskView(v)
+	buf := make([]byte, binary.MaxVarintLen64+len(data))
+	n := binary.PutVarint(buf, unixNano(v.expire))
+	n += copy(buf[n:], data)
+	return buf[:n]
+}
+
+func decodeSlabView(data []byte) (ByteView, bool) {
+	expire, n := binary.Varint(data)
+	if n <= 0 {
+		return ByteView{}, false
+	}
+	return decodeDiskView(data[n:], fromUnixNano(expire))
+}
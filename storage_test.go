@@ -0,0 +1,79 @@
+package simpleCache
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlabStorage(t *testing.T) {
+	reg := NewRegistry()
+	loads := 0
+	g := newTestGroup(t, reg, "slab", 4<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(strings.Repeat(key, 10)), nil
+		}), WithSlabStorage(), WithTTL(time.Hour), WithDiskTier(t.TempDir(), 0))
+	defer g.Close()
+
+	for i := 0; i < 2; i++ {
+		view, err := g.Get("k1")
+		if err != nil || view.String() != strings.Repeat("k1", 10) {
+			t.Fatalf("get k1 got %v, %v", view, err)
+		}
+		if view.Version() != versionOf(view.ByteSlice()) || view.Expire().IsZero() || view.Origin() == "" {
+			t.Fatalf("metadata of k1 is lost")
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("k1 loaded %d times", loads)
+	}
+	if _, ok := g.mainCache.mem.(slabStorage); !ok {
+		t.Fatalf("group uses %T", g.mainCache.mem)
+	}
+
+	_ = g.Set("ct", []byte("{}"), SetContentType("application/json"), SetTTL(time.Millisecond))
+	if view, _ := g.Get("ct"); view.ContentType() != "application/json" {
+		t.Fatalf("content type is %q", view.ContentType())
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := g.mainCache.get("ct"); ok {
+		t.Fatal("expired data should miss")
+	}
+
+	// 写满缓冲区, 旧数据被挤到磁盘上
+	for i := 0; i < 200; i++ {
+		_, _ = g.Get(fmt.Sprintf("key-%03d", i))
+	}
+	if st := g.Stats(); st.Bytes > 4<<10 || st.Items >= 200 {
+		t.Fatalf("slab holds %d items, %d bytes", st.Items, st.Bytes)
+	}
+	n := loads
+	if view, _ := g.Get("key-000"); view.String() != strings.Repeat("key-000", 10) || loads != n {
+		t.Fatal("evicted data should be read back from disk")
+	}
+
+	// 快照按从旧到新的顺序导出
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := newTestGroup(t, reg, "slab-dst", 4<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Fatalf("restored key %s should not be loaded", key)
+			return nil, nil
+		}), WithSlabStorage())
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Stats().Items != g.Stats().Items {
+		t.Fatalf("restored %d items, want %d", dst.Stats().Items, g.Stats().Items)
+	}
+
+	g.Resize(1 << 10)
+	if st := g.Stats(); st.Bytes > 1<<10 {
+		t.Fatalf("after resize slab holds %d bytes", st.Bytes)
+	}
+}
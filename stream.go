@@ -0,0 +1,154 @@
+package simpleCache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"simpleCache/pb"
+	"sync/atomic"
+	"time"
+)
+
+// GetReader 和Get一样获取key对应的数据, 但以io.ReadCloser的形式返回
+// 统计信息、事件、后台刷新和singleflight都和Get共用
+// 本地缓存未命中且key由支持流式传输的peer负责时, 发起加载的调用方直接从网络中流式读取数据,
+// 读到的数据同时保存下来, 同一时间等待这个key的其它调用方在数据流读完后共享完整的数据
+// 调用方需要读完或关闭返回的reader, 否则等待的调用方会一直阻塞
+func (g *Group) GetReader(key string) (io.ReadCloser, error) {
+	if key == "" {
+		return nil, errors.New("get a empty key")
+	}
+	if g.isClosed() {
+		return nil, ErrGroupClosed
+	}
+
+	if data, ok := g.lookupCache(key); ok {
+		return data.reader()
+	}
+
+	peer, ok := g.streamPeer(key)
+	if !ok {
+		data, err := g.load(key)
+		if err != nil {
+			return nil, err
+		}
+		return data.reader()
+	}
+
+	// 只有执行加载的调用方会从leader收到数据流, 其它调用方从ch收到共享的结果
+	leader := make(chan io.ReadCloser, 1)
+	ch := g.loader.DoChan(key, func() (any, error) {
+		return g.loadStream(peer, key, leader)
+	})
+	select {
+	case r := <-leader:
+		return r, nil
+	case res := <-ch:
+		if res.Err != nil {
+			data, err := g.serveStale(key, res.Err)
+			if err != nil {
+				return nil, err
+			}
+			return data.reader()
+		}
+		return res.Val.(ByteView).reader()
+	}
+}
+
+// loadStream 在singleflight中执行, 把数据流交给发起加载的调用方, 等它读完后返回完整的数据
+// 数据流打开失败时和fetch一样从本地数据源加载
+func (g *Group) loadStream(peer StreamPeerGetter, key string, leader chan<- io.ReadCloser) (any, error) {
+	atomic.AddInt64(&g.stats.loads, 1)
+	g.emit(Event{Type: EventLoadStart, Key: key})
+	start := time.Now()
+
+	out := &pb.Response{}
+	r, err := g.getStreamFromPeer(peer, key, out)
+	if err != nil {
+		// 失败了就打日志+本地执行回调
+		log.Printf("get stream(key:%s) from peer failed: %v", key, err)
+		data, err := g.getLocally(key)
+		g.emit(Event{Type: EventLoadDone, Key: key, Value: data, Err: err, Duration: time.Since(start)})
+		return data, err
+	}
+
+	tee := &teeStream{r: r, done: make(chan struct{})}
+	leader <- tee
+	<-tee.done
+
+	var data ByteView
+	if err = tee.err; err == nil {
+		// 流式返回的数据已经解压过了
+		out.Value = tee.buf.Bytes()
+		data, err = decodePeerResponse(out, nil)
+	}
+	g.emit(Event{Type: EventLoadDone, Key: key, Value: data, Err: err, Duration: time.Since(start)})
+	return data, err
+}
+
+// streamPeer 负责key的peer支持流式传输时返回这个peer
+func (g *Group) streamPeer(key string) (StreamPeerGetter, bool) {
+	peers := g.peerPicker()
+	if peers == nil {
+		return nil, false
+	}
+	peer, ok := peers.PickPeer(key)
+	if !ok {
+		return nil, false
+	}
+	sp, ok := peer.(StreamPeerGetter)
+	return sp, ok
+}
+
+// getStreamFromPeer 和getFromPeer一样记录统计信息和事件, 事件中没有数据内容
+func (g *Group) getStreamFromPeer(peer StreamPeerGetter, key string, out *pb.Response) (io.ReadCloser, error) {
+	atomic.AddInt64(&g.stats.peerLoads, 1)
+	start := time.Now()
+	r, err := peer.GetStreamFromPeer(&pb.Request{Group: g.name, Key: key}, out)
+	if err != nil {
+		atomic.AddInt64(&g.stats.peerErrors, 1)
+	}
+	g.emit(Event{Type: EventPeerFetch, Key: key, Err: err, Duration: time.Since(start)})
+	return r, err
+}
+
+// teeStream 交给发起加载的调用方的数据流, 读到的数据同时写入buf
+// 读到结尾、出错或者被关闭时关闭done, 之后buf不再变化, 可以交给等待的调用方
+// 只会被发起加载的调用方使用, 不需要加锁
+type teeStream struct {
+	r        io.ReadCloser
+	buf      bytes.Buffer
+	err      error
+	finished bool
+	done     chan struct{}
+}
+
+func (t *teeStream) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if t.finished {
+		return n, err
+	}
+	t.buf.Write(p[:n])
+	if err == io.EOF {
+		t.finish(nil)
+	} else if err != nil {
+		t.finish(err)
+	}
+	return n, err
+}
+
+// Close 没有读完时先把剩下的数据读进buf, 等待的调用方仍然可以得到完整的数据
+func (t *teeStream) Close() error {
+	if !t.finished {
+		_, err := t.buf.ReadFrom(t.r)
+		t.finish(err)
+	}
+	return t.r.Close()
+}
+
+func (t *teeStream) finish(err error) {
+	t.finished = true
+	t.err = err
+	close(t.done)
+}
@@ -0,0 +1,193 @@
+package simpleCache
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"simpleCache/pb"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingStreamPeer 流式请求在release关闭之前阻塞, 记录两种请求的次数
+type blockingStreamPeer struct {
+	value   string
+	release chan struct{}
+	streams int32
+	gets    int32
+}
+
+func (p *blockingStreamPeer) GetDataFromPeer(in *pb.Request, out *pb.Response) error {
+	atomic.AddInt32(&p.gets, 1)
+	<-p.release
+	out.Value = []byte(p.value)
+	return nil
+}
+
+func (p *blockingStreamPeer) GetStreamFromPeer(in *pb.Request, out *pb.Response) (io.ReadCloser, error) {
+	atomic.AddInt32(&p.streams, 1)
+	<-p.release
+	return io.NopCloser(strings.NewReader(p.value)), nil
+}
+
+func TestGetReader(t *testing.T) {
+	// 负责key的节点使用单独的Registry, 和真实部署一样与请求方互不影响
+	ownerReg := NewRegistry()
+	large := strings.Repeat("0123456789", 1000)
+	owner := newTestGroup(t, ownerReg, "stream", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(large), nil
+		}), WithTTL(time.Hour), WithCompression(GzipCompressor{Level: 6}, 1024))
+
+	pool := ownerReg.NewHttpPool("owner")
+	pool.SetStreamThreshold(64)
+	server := httptest.NewServer(pool)
+	defer server.Close()
+	getter := NewHttpGetter(server.URL + defaultBasePath)
+
+	sim := newTestGroup(t, NewRegistry(), "stream", 2<<20, GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Fatalf("key %s should be loaded from the owner", key)
+			return nil, nil
+		}))
+	sim.RegisterPeerPicker(staticPicker{peer: getter})
+
+	r, err := sim.GetReader("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != large {
+		t.Fatal("streamed value is not equal to the original value")
+	}
+
+	// 流式返回时元数据在header中, out中没有数据内容
+	out := &pb.Response{}
+	r, err = getter.GetStreamFromPeer(&pb.Request{Group: "stream", Key: "k"}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(r)
+	r.Close()
+	if string(data) != large || out.Value != nil {
+		t.Fatal("large value should be streamed")
+	}
+	if out.Version != versionOf([]byte(large)) || out.Origin != defaultOrigin || out.Expire == 0 {
+		t.Fatalf("metadata is lost when streaming: %+v", out)
+	}
+
+	// 本地命中时直接读取缓存, 压缩过的数据边读边解压
+	r, err = owner.GetReader("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(r)
+	r.Close()
+	if string(data) != large {
+		t.Fatal("local value is not equal to the original value")
+	}
+	if s := owner.Stats(); s.Gets != 3 || s.Hits != 2 {
+		t.Fatalf("GetReader should count gets and hits like Get: %+v", s)
+	}
+}
+
+func TestGetReaderSingleflight(t *testing.T) {
+	reg := NewRegistry()
+	loadDone := make(chan string, 10)
+	g := newTestGroup(t, reg, "stream-flight", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Fatalf("key %s should be loaded from the peer", key)
+			return nil, nil
+		}), WithListener(func(e Event) {
+		if e.Type == EventLoadDone {
+			loadDone <- e.Value.String()
+		}
+	}))
+	peer := &blockingStreamPeer{value: "remote", release: make(chan struct{})}
+	g.RegisterPeerPicker(staticPicker{peer: peer})
+
+	var wg sync.WaitGroup
+	read := func() {
+		defer wg.Done()
+		r, err := g.GetReader("k")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer r.Close()
+		if data, _ := io.ReadAll(r); string(data) != "remote" {
+			t.Errorf("GetReader = %q, want remote", data)
+		}
+	}
+	// 第一个调用方打开数据流之后, 其它调用方再加入
+	wg.Add(1)
+	go read()
+	for atomic.LoadInt32(&peer.streams) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go read()
+	}
+	// Get也会等待同一次流式加载
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if v, err := g.Get("k"); err != nil || v.String() != "remote" {
+			t.Errorf("Get = %q, %v, want remote", v, err)
+		}
+	}()
+	time.Sleep(30 * time.Millisecond)
+	close(peer.release)
+	wg.Wait()
+
+	// 只有发起加载的调用方打开数据流, 其它调用方共享读到的数据
+	if peer.streams != 1 || peer.gets != 0 {
+		t.Fatalf("concurrent reads opened %d streams and %d loads", peer.streams, peer.gets)
+	}
+	if s := g.Stats(); s.Gets != 6 || s.Loads != 1 || s.PeerLoads != 1 {
+		t.Fatalf("GetReader should share the accounting of Get: %+v", s)
+	}
+	// 加载结束事件在数据流读完之后才发出, 带有完整的数据
+	select {
+	case v := <-loadDone:
+		if v != "remote" {
+			t.Fatalf("load-done event carries %q, want remote", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("load-done event is missing")
+	}
+}
+
+func TestServeStreamAbort(t *testing.T) {
+	// 随机数据几乎无法压缩, 出错之前已经有数据发给了请求方
+	large := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(large)
+	compressed, err := GzipCompressor{Level: 6}.Compress(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 压缩数据被截断, 解压到一半时出错
+	broken := ByteView{b: compressed[:len(compressed)/2], encoding: "gzip"}
+
+	pool := NewRegistry().NewHttpPool("owner")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pool.serveStream(w, broken)
+	}))
+	defer server.Close()
+
+	// 出错时可能还没有发出响应头, 请求或读取数据任意一步失败都可以
+	r, err := NewHttpGetter(server.URL).GetStreamFromPeer(&pb.Request{Group: "g", Key: "k"}, &pb.Response{})
+	if err == nil {
+		_, err = io.ReadAll(r)
+		r.Close()
+	}
+	if err == nil {
+		t.Fatal("truncated stream should fail instead of ending cleanly")
+	}
+}
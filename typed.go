@@ -0,0 +1,146 @@
+package simpleCache
+
+import (
+	"errors"
+	"simpleCache/lru"
+	"sync"
+)
+
+// TypedGetter 由用户传入的回调函数, 直接返回V而不是[]byte
+type TypedGetter[V any] interface {
+	Get(key string) (V, error)
+}
+
+type TypedGetterFunc[V any] func(key string) (V, error)
+
+func (f TypedGetterFunc[V]) Get(key string) (V, error) {
+	return f(key)
+}
+
+// defaultDecodedBytes 解码结果缓存的默认大小
+const defaultDecodedBytes = 8 << 20
+
+// TypedGroup 在Group之上增加了类型信息
+// 缓存和节点之间传输的仍然是Codec编码后的[]byte
+// 解码后的对象会在本地缓存, 数据版本不变时不会重复解码, 大小上限见WithDecodedBytes
+// 注意: 返回的V会被多个调用方共享, 调用方不应该修改它
+type TypedGroup[V any] struct {
+	group *Group
+	codec Codec[V]
+
+	mu      sync.Mutex
+	decoded *lru.Cache // 解码后的对象, 大小按编码后的字节数计算, 关闭时为nil
+}
+
+// TypedOption 创建TypedGroup时的额外配置
+type TypedOption func(o *typedOptions)
+
+type typedOptions struct {
+	decodedBytes int64
+	groupOpts    []Option
+}
+
+// WithDecodedBytes 解码结果缓存的大小上限, 按编码后的字节数计算, 默认8MB
+// 解码结果不计入cacheBytes和共享预算, 不大于0时不缓存, 每次Get都重新解码
+func WithDecodedBytes(n int64) TypedOption {
+	return func(o *typedOptions) {
+		o.decodedBytes = n
+	}
+}
+
+// WithGroupOptions 创建底层Group时使用的Option
+func WithGroupOptions(opts ...Option) TypedOption {
+	return func(o *typedOptions) {
+		o.groupOpts = append(o.groupOpts, opts...)
+	}
+}
+
+// NewTypedGroup 在DefaultRegistry中创建TypedGroup, 出错时panic
+func NewTypedGroup[V any](name string, cacheBytes int64, getter TypedGetter[V], codec Codec[V], opts ...TypedOption) *TypedGroup[V] {
+	t, err := NewTypedGroupIn(DefaultRegistry, name, cacheBytes, getter, codec, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// NewTypedGroupIn 在r中创建TypedGroup, 同名的group已经存在时返回ErrGroupExists
+func NewTypedGroupIn[V any](r *Registry, name string, cacheBytes int64, getter TypedGetter[V], codec Codec[V], opts ...TypedOption) (*TypedGroup[V], error) {
+	if getter == nil {
+		return nil, errors.New("getter is nil")
+	}
+	if codec == nil {
+		return nil, errors.New("codec is nil")
+	}
+	o := typedOptions{decodedBytes: defaultDecodedBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	g, err := r.NewGroup(name, cacheBytes, GetterFunc(
+		func(key string) ([]byte, error) {
+			v, err := getter.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			return codec.Encode(v)
+		}), o.groupOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TypedGroup[V]{group: g, codec: codec}
+	// 解码结果单独设置上限, 不能和cacheBytes共用, 否则内存占用会翻倍
+	if o.decodedBytes > 0 {
+		t.decoded = lru.New(o.decodedBytes, nil)
+	}
+	return t, nil
+}
+
+// Group 返回底层的Group, 可以用来注册PeerPicker等
+func (t *TypedGroup[V]) Group() *Group {
+	return t.group
+}
+
+// Get 和Group.Get一样, 但是返回解码后的V
+func (t *TypedGroup[V]) Get(key string) (V, error) {
+	var zero V
+	data, err := t.group.Get(key)
+	if err != nil {
+		return zero, err
+	}
+	if t.decoded == nil {
+		return t.codec.Decode(data.bytes())
+	}
+
+	t.mu.Lock()
+	if val, ok := t.decoded.Get(key); ok {
+		e := val.(decodedEntry[V])
+		if data.Version() != "" && e.version == data.Version() {
+			t.mu.Unlock()
+			return e.value, nil
+		}
+	}
+	t.mu.Unlock()
+
+	v, err := t.codec.Decode(data.bytes())
+	if err != nil {
+		return zero, err
+	}
+
+	t.mu.Lock()
+	t.decoded.Add(key, decodedEntry[V]{version: data.Version(), value: v, size: data.Len()})
+	t.mu.Unlock()
+	return v, nil
+}
+
+// decodedEntry 本地缓存的解码结果, version用于判断数据是否变化
+type decodedEntry[V any] struct {
+	version string
+	value   V
+	size    int
+}
+
+func (e decodedEntry[V]) Len() int {
+	return e.size
+}
@@ -0,0 +1,102 @@
+package simpleCache
+
+import (
+	"fmt"
+	"simpleCache/pb"
+	"testing"
+	"time"
+)
+
+type score struct {
+	Name  string
+	Score int
+}
+
+// countingCodec 统计解码次数
+type countingCodec[V any] struct {
+	Codec[V]
+	decodes int
+}
+
+func (c *countingCodec[V]) Decode(data []byte) (V, error) {
+	c.decodes++
+	return c.Codec.Decode(data)
+}
+
+func TestTypedGroup(t *testing.T) {
+	codec := &countingCodec[score]{Codec: JSONCodec[score]{}}
+	sim, err := NewTypedGroupIn[score](NewRegistry(), "typed-scores", 2<<10, TypedGetterFunc[score](
+		func(key string) (score, error) {
+			if v, ok := db[key]; ok {
+				var s int
+				_, _ = fmt.Sscan(v, &s)
+				return score{Name: key, Score: s}, nil
+			}
+			return score{}, fmt.Errorf("%s not exist", key)
+		}), codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		v, err := sim.Get("Tom")
+		if err != nil || v != (score{Name: "Tom", Score: 630}) {
+			t.Fatalf("failed to get typed value of Tom: %v %v", v, err)
+		}
+	}
+	if codec.decodes != 1 {
+		t.Fatalf("decoded value should be cached, decodes %d", codec.decodes)
+	}
+	if _, err := sim.Get("unknown"); err == nil {
+		t.Fatal("the value of unknown should be empty")
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	s := score{Name: "Jack", Score: 589}
+	for name, codec := range map[string]Codec[score]{
+		"json": JSONCodec[score]{},
+		"gob":  GobCodec[score]{},
+	} {
+		data, err := codec.Encode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := codec.Decode(data); err != nil || v != s {
+			t.Fatalf("%s codec round trip failed: %v %v", name, v, err)
+		}
+	}
+
+	req := &pb.Request{Group: "scores", Key: "Sam"}
+	var codec Codec[*pb.Request] = ProtoCodec[*pb.Request]{}
+	data, err := codec.Encode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := codec.Decode(data); err != nil || v.GetGroup() != "scores" || v.GetKey() != "Sam" {
+		t.Fatalf("proto codec round trip failed: %v %v", v, err)
+	}
+}
+
+func TestTypedGroupWithoutDecodedCache(t *testing.T) {
+	codec := &countingCodec[score]{Codec: JSONCodec[score]{}}
+	sim, err := NewTypedGroupIn[score](NewRegistry(), "typed-no-decoded", 2<<10, TypedGetterFunc[score](
+		func(key string) (score, error) {
+			return score{Name: key}, nil
+		}), codec, WithDecodedBytes(0), WithGroupOptions(WithTTL(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if v, err := sim.Get("Tom"); err != nil || v.Name != "Tom" {
+			t.Fatalf("failed to get typed value of Tom: %v %v", v, err)
+		}
+	}
+	if sim.decoded != nil || codec.decodes != 2 {
+		t.Fatalf("decoded values should not be cached when disabled, decodes %d", codec.decodes)
+	}
+	if v, _ := sim.Group().Peek("Tom"); v.Expire().IsZero() {
+		t.Fatalf("group options should be passed to the underlying group")
+	}
+}